@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/queue"
+	cli "github.com/jawher/mow.cli"
+)
+
+// routingCase is an origin system and content type to resolve, from the cases table or a sample message
+type routingCase struct {
+	Source       string `json:"-"`
+	OriginSystem string `json:"origin_system"`
+	ContentType  string `json:"content_type"`
+}
+
+// checkConfig is the check-config command, which resolves the collection of messages with a config file
+// without connecting to Kafka or the native writer, so that config changes can be checked in CI
+func checkConfig(cmd *cli.Cmd) {
+	cmd.Spec = "--config [--cases] [--samples] [--expectations]"
+	configFile := cmd.String(cli.StringOpt{
+		Name:   "config",
+		Value:  "",
+		Desc:   "Config file (e.g. config.json)",
+		EnvVar: "CONFIG",
+	})
+	casesFile := cmd.String(cli.StringOpt{
+		Name:  "cases",
+		Value: "",
+		Desc:  "JSON file with an array of messages to resolve, e.g. [{\"origin_system\": \"http://cmdb.ft.com/systems/spark\", \"content_type\": \"application/json\"}]",
+	})
+	samplesDir := cmd.String(cli.StringOpt{
+		Name:  "samples",
+		Value: "",
+		Desc:  "Directory of sample messages in the FT message format, resolved by their Origin-System-Id and Content-Type headers",
+	})
+	expectationsFile := cmd.String(cli.StringOpt{
+		Name:  "expectations",
+		Value: "",
+		Desc:  "JSON file with an array of expected routings, e.g. [{\"origin_system\": \"http://cmdb.ft.com/systems/spark\", \"content_type\": \"application/json\", \"collection\": \"universal-content\"}]",
+	})
+
+	cmd.Action = func() {
+		if !runCheckConfig(os.Stdout, *configFile, *casesFile, *samplesDir, *expectationsFile) {
+			cli.Exit(1)
+		}
+	}
+}
+
+// runCheckConfig prints the routing of the cases, the samples and the expectations, and returns false
+// if the config is invalid, a file cannot be read or a message is not routed as expected
+func runCheckConfig(out io.Writer, configFile string, casesFile string, samplesDir string, expectationsFile string) bool {
+	conf, err := config.ReadConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(out, "Invalid configuration %v:\n", configFile)
+		if problems, ok := err.(config.ValidationErrors); ok {
+			for _, problem := range problems {
+				fmt.Fprintf(out, "  %v\n", problem)
+			}
+		} else {
+			fmt.Fprintf(out, "  %v\n", err)
+		}
+		return false
+	}
+
+	var cases []routingCase
+	if casesFile != "" {
+		if cases, err = readRoutingCases(casesFile); err != nil {
+			fmt.Fprintf(out, "Error reading the cases %v: %v\n", casesFile, err)
+			return false
+		}
+	}
+	if samplesDir != "" {
+		samples, err := readSampleMessages(samplesDir)
+		if err != nil {
+			fmt.Fprintf(out, "Error reading the sample messages %v: %v\n", samplesDir, err)
+			return false
+		}
+		cases = append(cases, samples...)
+	}
+	var expectations []config.Expectation
+	if expectationsFile != "" {
+		file, err := os.Open(expectationsFile)
+		if err == nil {
+			expectations, err = config.ReadExpectations(file)
+			file.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Error reading the expectations %v: %v\n", expectationsFile, err)
+			return false
+		}
+	}
+
+	var resolutions []config.Resolution
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tORIGIN SYSTEM\tCONTENT TYPE\tCOLLECTION\tROUTE")
+	for _, c := range cases {
+		r := conf.Resolve(c.OriginSystem, c.ContentType)
+		resolutions = append(resolutions, r)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", c.Source, c.OriginSystem, c.ContentType, r, r.Route)
+	}
+	mismatches := 0
+	for _, e := range expectations {
+		r := conf.Resolve(e.OriginSystem, e.ContentType)
+		resolutions = append(resolutions, r)
+		result := r.String()
+		if !e.Matches(r) {
+			mismatches++
+			result = fmt.Sprintf("%v, expected %v", r, e)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", expectationsFile, e.OriginSystem, e.ContentType, result, r.Route)
+	}
+	w.Flush()
+
+	if len(resolutions) > 0 {
+		for _, route := range conf.UnusedRoutes(resolutions) {
+			fmt.Fprintf(out, "Warning: route %v matches none of the messages\n", route)
+		}
+	}
+	if mismatches > 0 {
+		fmt.Fprintf(out, "%v of %v messages are not routed as expected\n", mismatches, len(expectations))
+		return false
+	}
+	return true
+}
+
+func readRoutingCases(path string) ([]routingCase, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []routingCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, err
+	}
+	for i := range cases {
+		cases[i].Source = fmt.Sprintf("%v[%v]", path, i)
+	}
+	return cases, nil
+}
+
+// readSampleMessages reads the files of the directory as messages in the FT message format
+func readSampleMessages(dir string) ([]routingCase, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cases []routingCase
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		msg := queue.ParseFTMessage(raw)
+		cases = append(cases, routingCase{
+			Source:       path,
+			OriginSystem: strings.TrimSpace(msg.Headers["Origin-System-Id"]),
+			ContentType:  msg.Headers["Content-Type"],
+		})
+	}
+	return cases, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const checkedConfig = `{
+	"http://cmdb.ft.com/systems/methode-web-pub": [
+		{"content_type": "^application/json", "collection": "methode"},
+		{"content_type": "^text/html", "skip": true}
+	],
+	"http://cmdb.ft.com/systems/spark": [
+		{"content_type": ".*", "collection": "universal-content"}
+	]
+}`
+
+const sampleMessage = "FTMSG/1.0\r\n" +
+	"Origin-System-Id: http://cmdb.ft.com/systems/spark\r\n" +
+	"Content-Type: application/vnd.ft-upp-article+json\r\n" +
+	"X-Request-Id: tid_sample\r\n" +
+	"\r\n" +
+	`{"uuid": "572d0acc-3f12-4e70-8830-8092c1042a52"}`
+
+func writeCheckConfigFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestRunCheckConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-check-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	validConfig := writeCheckConfigFile(t, dir, "config.json", checkedConfig)
+	invalidConfig := writeCheckConfigFile(t, dir, "invalid.json", `{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": "^application/json"}
+		]
+	}`)
+	cases := writeCheckConfigFile(t, dir, "cases.json", `[
+		{"origin_system": "http://cmdb.ft.com/systems/methode-web-pub", "content_type": "application/json; version=1.0"},
+		{"origin_system": "http://cmdb.ft.com/systems/wordpress", "content_type": "application/json"}
+	]`)
+	samples := filepath.Dir(writeCheckConfigFile(t, dir, "samples/spark.msg", sampleMessage))
+	writeCheckConfigFile(t, dir, "samples/.hidden", "not a message")
+	expectations := writeCheckConfigFile(t, dir, "expectations.json", `[
+		{"origin_system": "http://cmdb.ft.com/systems/methode-web-pub", "content_type": "text/html", "skip": true},
+		{"origin_system": "http://cmdb.ft.com/systems/spark", "content_type": "application/json", "collection": "universal-content"}
+	]`)
+	wrongExpectations := writeCheckConfigFile(t, dir, "wrong-expectations.json", `[
+		{"origin_system": "http://cmdb.ft.com/systems/spark", "content_type": "application/json", "collection": "methode"}
+	]`)
+	invalidExpectations := writeCheckConfigFile(t, dir, "invalid-expectations.json", `{"collection": "methode"}`)
+
+	tests := []struct {
+		name         string
+		config       string
+		cases        string
+		samples      string
+		expectations string
+		expOK        bool
+		expOutput    []string
+	}{
+		{
+			name:   "valid config without messages",
+			config: validConfig,
+			expOK:  true,
+		},
+		{
+			name:         "valid config routing every message as expected",
+			config:       validConfig,
+			cases:        cases,
+			samples:      samples,
+			expectations: expectations,
+			expOK:        true,
+			expOutput: []string{
+				"SOURCE",
+				cases + "[0]",
+				"methode",
+				cases + "[1]",
+				"not routed (origin system not found)",
+				filepath.Join(samples, "spark.msg"),
+				"application/vnd.ft-upp-article+json",
+				"universal-content",
+				"skipped",
+			},
+		},
+		{
+			name:   "invalid config",
+			config: invalidConfig,
+			cases:  cases,
+			expOK:  false,
+			expOutput: []string{
+				"Invalid configuration " + invalidConfig,
+				`config["http://cmdb.ft.com/systems/methode-web-pub"][0].collection`,
+			},
+		},
+		{
+			name:      "missing config",
+			config:    filepath.Join(dir, "missing.json"),
+			expOK:     false,
+			expOutput: []string{"Invalid configuration"},
+		},
+		{
+			name:         "message not routed as expected",
+			config:       validConfig,
+			expectations: wrongExpectations,
+			expOK:        false,
+			expOutput: []string{
+				"universal-content, expected methode",
+				"1 of 1 messages are not routed as expected",
+			},
+		},
+		{
+			name:      "unreadable samples",
+			config:    validConfig,
+			samples:   filepath.Join(dir, "missing-samples"),
+			expOK:     false,
+			expOutput: []string{"Error reading the sample messages " + filepath.Join(dir, "missing-samples")},
+		},
+		{
+			name:      "unreadable cases",
+			config:    validConfig,
+			cases:     filepath.Join(dir, "missing-cases.json"),
+			expOK:     false,
+			expOutput: []string{"Error reading the cases"},
+		},
+		{
+			name:         "invalid expectations",
+			config:       validConfig,
+			expectations: invalidExpectations,
+			expOK:        false,
+			expOutput:    []string{"Error reading the expectations " + invalidExpectations},
+		},
+		{
+			name:      "unused route",
+			config:    validConfig,
+			samples:   samples,
+			expOK:     true,
+			expOutput: []string{`Warning: route config["http://cmdb.ft.com/systems/methode-web-pub"][0] matches none of the messages`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			ok := runCheckConfig(&out, tt.config, tt.cases, tt.samples, tt.expectations)
+
+			assert.Equal(t, tt.expOK, ok, "Unexpected result with the output:\n%v", out.String())
+			for _, expected := range tt.expOutput {
+				assert.Contains(t, out.String(), expected)
+			}
+		})
+	}
+}
+
+func TestRunCheckConfigWarnsOnlyWithMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-check-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var out bytes.Buffer
+	assert.True(t, runCheckConfig(&out, writeCheckConfigFile(t, dir, "config.json", checkedConfig), "", "", ""))
+	assert.NotContains(t, out.String(), "Warning", "Routes should not be reported unused when no message is resolved")
+}
+
+func TestReadSampleMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-check-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeCheckConfigFile(t, dir, "spark.msg", sampleMessage)
+	writeCheckConfigFile(t, dir, ".hidden", "not a message")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0755))
+
+	cases, err := readSampleMessages(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []routingCase{{
+		Source:       path,
+		OriginSystem: "http://cmdb.ft.com/systems/spark",
+		ContentType:  "application/vnd.ft-upp-article+json",
+	}}, cases, "Hidden files and directories should be skipped")
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Resolution is how the messages of an origin system and content type are routed by a configuration
+type Resolution struct {
+	OriginSystem string
+	ContentType  string
+	// Route is the JSON path of the matching route, like config["http://cmdb.ft.com/systems/spark"][1], empty if none matches
+	Route      string
+	Collection string
+	Skip       bool
+	Err        error
+}
+
+// String returns the collection of the route, or how the messages are otherwise handled
+func (r Resolution) String() string {
+	switch {
+	case r.Err != nil:
+		return "not routed (" + r.Err.Error() + ")"
+	case r.Skip:
+		return "skipped"
+	}
+	return r.Collection
+}
+
+// Resolve returns how the messages of the origin system and content type are routed, as GetCollection does
+func (c *Configuration) Resolve(originID string, contentType string) Resolution {
+	resolution := Resolution{OriginSystem: originID, ContentType: contentType}
+	route, err := c.GetRoute(originID, contentType)
+	if err != nil {
+		resolution.Err = err
+		return resolution
+	}
+	resolution.Route = c.routePath(route)
+	resolution.Collection = route.Collection
+	resolution.Skip = route.Skip
+	return resolution
+}
+
+// routePath returns the JSON path of the route
+func (c *Configuration) routePath(route *OriginSystemConfig) string {
+	for origin, collection := range c.Config {
+		for i := range collection {
+			if &collection[i] == route {
+				return routePath(origin, i)
+			}
+		}
+	}
+	return ""
+}
+
+// UnusedRoutes returns the JSON paths of the routes that none of the resolutions matched, sorted
+func (c *Configuration) UnusedRoutes(resolutions []Resolution) []string {
+	used := make(map[string]bool)
+	for _, r := range resolutions {
+		used[r.Route] = true
+	}
+	var unused []string
+	for _, origin := range sortedOrigins(c.Config) {
+		for i := range c.Config[origin] {
+			if path := routePath(origin, i); !used[path] {
+				unused = append(unused, path)
+			}
+		}
+	}
+	return unused
+}
+
+// Expectation is the expected routing of the messages of an origin system and content type.
+// Like a route, it has either a collection or "skip": true; it has neither if the messages are expected not to be routed.
+type Expectation struct {
+	OriginSystem string `json:"origin_system"`
+	ContentType  string `json:"content_type"`
+	Collection   string `json:"collection,omitempty"`
+	Skip         bool   `json:"skip,omitempty"`
+}
+
+// Matches returns true if the messages are routed as expected
+func (e Expectation) Matches(r Resolution) bool {
+	if r.Err != nil {
+		return e.Collection == "" && !e.Skip
+	}
+	return r.Collection == e.Collection && r.Skip == e.Skip
+}
+
+// String returns the expected collection, or how the messages are otherwise expected to be handled
+func (e Expectation) String() string {
+	switch {
+	case e.Skip:
+		return "skipped"
+	case e.Collection == "":
+		return "not routed"
+	}
+	return e.Collection
+}
+
+// ReadExpectations reads a JSON array of expectations
+func ReadExpectations(r io.Reader) ([]Expectation, error) {
+	var expectations []Expectation
+	if err := json.NewDecoder(r).Decode(&expectations); err != nil {
+		return nil, err
+	}
+	return expectations, nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const checkedConfig = `{
+	"http://cmdb.ft.com/systems/spark": [
+		{"content_type": "^application/json", "collection": "universal-content"},
+		{"content_type": "^application/xml", "skip": true},
+		{"content_type": "^text/plain", "collection": "plain"}
+	],
+	"*": [
+		{"content_type": "*", "collection": "quarantine"}
+	]
+}`
+
+func TestResolve(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(checkedConfig))
+	if err != nil {
+		t.Fatalf("ReadConfigFromReader() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		originID    string
+		contentType string
+		expRoute    string
+		expString   string
+	}{
+		{"collection", "http://cmdb.ft.com/systems/spark", "application/json", `config["http://cmdb.ft.com/systems/spark"][0]`, "universal-content"},
+		{"skipped", "http://cmdb.ft.com/systems/spark", "application/xml", `config["http://cmdb.ft.com/systems/spark"][1]`, "skipped"},
+		{"catch-all", "http://cmdb.ft.com/systems/wordpress", "application/json", `config["*"][0]`, "quarantine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := c.Resolve(tt.originID, tt.contentType)
+			if r.Route != tt.expRoute || r.String() != tt.expString {
+				t.Errorf("Resolve() = (%v, %v), want (%v, %v)", r.Route, r, tt.expRoute, tt.expString)
+			}
+		})
+	}
+
+	delete(c.Config, CatchAll)
+	r := c.Resolve("http://cmdb.ft.com/systems/wordpress", "application/json")
+	if r.Err == nil || r.Route != "" || r.String() != "not routed (origin system not found)" {
+		t.Errorf("Resolve() = (%v, %v), want not routed", r.Route, r)
+	}
+}
+
+func TestUnusedRoutes(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(checkedConfig))
+	if err != nil {
+		t.Fatalf("ReadConfigFromReader() error = %v", err)
+	}
+	resolutions := []Resolution{
+		c.Resolve("http://cmdb.ft.com/systems/spark", "application/json; charset=utf-8"),
+		c.Resolve("http://cmdb.ft.com/systems/spark", "application/xml"),
+	}
+	expected := []string{`config["*"][0]`, `config["http://cmdb.ft.com/systems/spark"][2]`}
+	if got := c.UnusedRoutes(resolutions); !reflect.DeepEqual(got, expected) {
+		t.Errorf("UnusedRoutes() = %v, want %v", got, expected)
+	}
+}
+
+func TestExpectationMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		expectation Expectation
+		resolution  Resolution
+		expected    bool
+	}{
+		{"same collection", Expectation{Collection: "video"}, Resolution{Collection: "video"}, true},
+		{"other collection", Expectation{Collection: "video"}, Resolution{Collection: "audio"}, false},
+		{"skipped", Expectation{Skip: true}, Resolution{Skip: true}, true},
+		{"skipped instead of written", Expectation{Collection: "video"}, Resolution{Skip: true}, false},
+		{"not routed", Expectation{}, Resolution{Err: errors.New("origin system not found")}, true},
+		{"not routed instead of written", Expectation{Collection: "video"}, Resolution{Err: errors.New("origin system not found")}, false},
+		{"written instead of not routed", Expectation{}, Resolution{Collection: "video"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.expectation.Matches(tt.resolution); got != tt.expected {
+				t.Errorf("Matches() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadExpectations(t *testing.T) {
+	expectations, err := ReadExpectations(strings.NewReader(`[
+		{"origin_system": "http://cmdb.ft.com/systems/spark", "content_type": "application/json", "collection": "universal-content"},
+		{"origin_system": "http://cmdb.ft.com/systems/spark", "content_type": "application/xml", "skip": true}
+	]`))
+	if err != nil {
+		t.Fatalf("ReadExpectations() error = %v", err)
+	}
+	expected := []Expectation{
+		{OriginSystem: "http://cmdb.ft.com/systems/spark", ContentType: "application/json", Collection: "universal-content"},
+		{OriginSystem: "http://cmdb.ft.com/systems/spark", ContentType: "application/xml", Skip: true},
+	}
+	if !reflect.DeepEqual(expectations, expected) {
+		t.Errorf("ReadExpectations() = %v, want %v", expectations, expected)
+	}
+}
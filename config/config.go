@@ -3,15 +3,116 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Financial-Times/native-ingester/jsonpath"
+	"github.com/Financial-Times/native-ingester/xpath"
+	uuid "github.com/satori/go.uuid"
 )
 
+// CatchAll is the origin system whose routes apply to messages no other route matches,
+// and the content type of the fallback route of an origin system
+const CatchAll = "*"
+
+// ErrRouteSkipped is returned for messages whose route is explicitly marked to be skipped
+var ErrRouteSkipped = errors.New("route skips the content")
+
 type OriginSystemConfig struct {
-	ContentType       string `json:"content_type,binding:required"`
-	Collection        string `json:"collection,binding:required"`
+	ContentType string `json:"content_type,binding:required"`
+	Collection  string `json:"collection,binding:required"`
+	// Skip marks the messages of the route to be skipped rather than written to a collection
+	Skip bool `json:"skip,omitempty"`
+	// ForwardTopic is the topic the messages of the route are forwarded to instead of the default one
+	ForwardTopic string `json:"forward_topic,omitempty"`
+	// SkipForward marks the messages of the route to be written without being forwarded
+	SkipForward bool `json:"skip_forward,omitempty"`
+	// UUIDFields are the JSONPaths to the UUID of the content, tried in order instead of the global ones
+	UUIDFields []string `json:"uuid_fields,omitempty"`
+	// UUIDXMLFields are the XPaths to the UUID of XML content, tried in order instead of the global ones
+	UUIDXMLFields []string `json:"uuid_xml_fields,omitempty"`
+	// UUIDVersions are the versions of the UUIDs accepted for the route, any version is accepted if empty
+	UUIDVersions []int `json:"uuid_versions,omitempty"`
+	// UUIDDerivation builds the UUID of content that has none from another identifier
+	UUIDDerivation    *UUIDDerivation `json:"uuid_derivation,omitempty"`
 	contentTypeRegexp *regexp.Regexp
+	uuidPaths         []*jsonpath.Path
+	uuidXPaths        []*xpath.Path
+}
+
+// UUIDDerivation builds a name-based UUID, version 3 (MD5) or 5 (SHA-1), from a namespace and the value of a field of the content
+type UUIDDerivation struct {
+	Version   int    `json:"version"`
+	Namespace string `json:"namespace"`
+	Field     string `json:"field"`
+	namespace uuid.UUID
+	path      *jsonpath.Path
+}
+
+// uuidNamespaces are the namespaces predefined by RFC 4122, which can be given by name
+var uuidNamespaces = map[string]uuid.UUID{
+	"dns":  uuid.NamespaceDNS,
+	"url":  uuid.NamespaceURL,
+	"oid":  uuid.NamespaceOID,
+	"x500": uuid.NamespaceX500,
+}
+
+// check reports the problems of the derivation found at the given path
+func (d *UUIDDerivation) check(v *validator, path string) {
+	if d.Version != 3 && d.Version != 5 {
+		v.addf(path+".version", "must be 3 or 5, got %v", d.Version)
+	}
+	if ns, found := uuidNamespaces[strings.ToLower(d.Namespace)]; found {
+		d.namespace = ns
+	} else if ns, err := uuid.FromString(d.Namespace); err == nil {
+		d.namespace = ns
+	} else {
+		v.addf(path+".namespace", "%q is neither a UUID nor one of dns, url, oid and x500", d.Namespace)
+	}
+	if fieldPath, err := jsonpath.Compile(d.Field); err == nil {
+		d.path = fieldPath
+	} else {
+		v.addf(path+".field", "%v", err)
+	}
+}
+
+// Derive returns the UUID built from the first string or number matched by the field in the body, if any
+func (d *UUIDDerivation) Derive(body interface{}) (string, bool) {
+	for _, value := range d.path.Find(body) {
+		var name string
+		switch v := value.(type) {
+		case string:
+			name = v
+		case json.Number:
+			name = v.String()
+		case float64:
+			name = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if name == "" {
+			continue
+		}
+		if d.Version == 3 {
+			return uuid.NewV3(d.namespace, name).String(), true
+		}
+		return uuid.NewV5(d.namespace, name).String(), true
+	}
+	return "", false
+}
+
+// UUIDPaths returns the compiled UUID fields of the route, empty if the global ones should be used
+func (o *OriginSystemConfig) UUIDPaths() []*jsonpath.Path {
+	return o.uuidPaths
+}
+
+// UUIDXPaths returns the compiled UUID XPaths of the route, empty if the global ones should be used
+func (o *OriginSystemConfig) UUIDXPaths() []*xpath.Path {
+	return o.uuidXPaths
 }
 
 // Configuration data
@@ -19,43 +120,168 @@ type Configuration struct {
 	Config map[string][]OriginSystemConfig
 }
 
+// validateConfig compiles the routes and reports all their problems at once, as ValidationErrors
 func (c *Configuration) validateConfig() error {
-	for oKey, origCollection := range c.Config {
-		for ocKey, val := range origCollection {
-			if val.ContentType == "" {
-				return errors.New("contentType value is mandatory")
-			}
-			if val.Collection == "" {
-				return errors.New("collection value is mandatory")
-			}
-			c.Config[oKey][ocKey].contentTypeRegexp = regexp.MustCompile(val.ContentType)
+	v := &validator{}
+	for _, oKey := range sortedOrigins(c.Config) {
+		origCollection := c.Config[oKey]
+		if strings.TrimSpace(oKey) == "" {
+			v.addf(originPath(oKey), "origin system must not be empty")
+		}
+		if len(origCollection) == 0 {
+			v.addf(originPath(oKey), "origin system has no routes")
+		}
+		for ocKey := range origCollection {
+			c.validateRoute(v, oKey, ocKey)
 		}
 	}
-	return nil
+	return v.err()
 }
 
+func (c *Configuration) validateRoute(v *validator, oKey string, ocKey int) {
+	origCollection := c.Config[oKey]
+	val := &origCollection[ocKey]
+	path := routePath(oKey, ocKey)
+
+	switch {
+	case val.ContentType == "":
+		v.addf(path+".content_type", "contentType value is mandatory")
+	case val.ContentType == CatchAll:
+	default:
+		re, err := regexp.Compile(val.ContentType)
+		if err != nil {
+			v.addf(path+".content_type", "invalid regular expression: %v", err)
+		}
+		val.contentTypeRegexp = re
+	}
+	for i, previous := range origCollection[:ocKey] {
+		if previous.ContentType == val.ContentType && val.ContentType != "" {
+			v.addf(path, "duplicates route [%v], content type %q", i, val.ContentType)
+		} else if val.ContentType != CatchAll && matchesAnything(previous.ContentType) {
+			v.addf(path, "is shadowed by route [%v], whose content type %q matches any content type", i, previous.ContentType)
+		}
+	}
+
+	if val.Collection == "" && !val.Skip {
+		v.addf(path+".collection", "collection value is mandatory")
+	}
+	if val.Collection != "" && val.Skip {
+		v.addf(path+".skip", "a route cannot both skip the content and have a collection")
+	}
+	if val.ForwardTopic != "" && val.SkipForward {
+		v.addf(path+".skip_forward", "a route cannot both skip forwarding and have a forward topic")
+	}
+
+	val.uuidPaths = make([]*jsonpath.Path, 0, len(val.UUIDFields))
+	for i, field := range val.UUIDFields {
+		fieldPath, err := jsonpath.Compile(field)
+		if err != nil {
+			v.addf(fmt.Sprintf("%v.uuid_fields[%v]", path, i), "%v", err)
+			continue
+		}
+		val.uuidPaths = append(val.uuidPaths, fieldPath)
+	}
+	val.uuidXPaths = make([]*xpath.Path, 0, len(val.UUIDXMLFields))
+	for i, field := range val.UUIDXMLFields {
+		fieldPath, err := xpath.Compile(field)
+		if err != nil {
+			v.addf(fmt.Sprintf("%v.uuid_xml_fields[%v]", path, i), "%v", err)
+			continue
+		}
+		val.uuidXPaths = append(val.uuidXPaths, fieldPath)
+	}
+	for i, version := range val.UUIDVersions {
+		if version < 1 || version > 5 {
+			v.addf(fmt.Sprintf("%v.uuid_versions[%v]", path, i), "UUID version must be between 1 and 5, got %v", version)
+		}
+	}
+	if val.UUIDDerivation != nil {
+		val.UUIDDerivation.check(v, path+".uuid_derivation")
+	}
+}
+
+// HasOriginSystem returns true if the origin system has routes of its own
+func (c *Configuration) HasOriginSystem(originID string) bool {
+	_, found := c.Config[originID]
+	return found
+}
+
+// GetCollection returns the collection of the route of the origin system and content type,
+// or ErrRouteSkipped if the route is marked to be skipped
 func (c *Configuration) GetCollection(originID string, contentType string) (string, error) {
+	route, err := c.GetRoute(originID, contentType)
+	if err != nil {
+		return "", err
+	}
+	if route.Skip {
+		return "", ErrRouteSkipped
+	}
+	return route.Collection, nil
+}
+
+// GetRoute returns the first route of the origin system whose content type matches, or else its fallback route.
+// When the origin system is not configured or has no matching route, the routes of the catch-all origin system are tried in the same way.
+func (c *Configuration) GetRoute(originID string, contentType string) (*OriginSystemConfig, error) {
 	collection := c.Config[originID]
+	if route := matchRoute(collection, contentType); route != nil {
+		return route, nil
+	}
+	if route := matchRoute(c.Config[CatchAll], contentType); route != nil {
+		return route, nil
+	}
 	if len(collection) == 0 {
-		return "", errors.New("origin system not found")
+		return nil, errors.New("origin system not found")
+	}
+	return nil, errors.New("origin system and content type not configured")
+}
+
+func matchRoute(collection []OriginSystemConfig, contentType string) *OriginSystemConfig {
+	var fallback *OriginSystemConfig
+	for i, val := range collection {
+		if val.ContentType == CatchAll {
+			fallback = &collection[i]
+		} else if val.contentTypeRegexp.MatchString(contentType) {
+			return &collection[i]
+		}
 	}
-	for _, val := range collection {
-		if val.contentTypeRegexp.MatchString(contentType) {
-			return val.Collection, nil
+	return fallback
+}
+
+// ForwardTopics returns the forward topics named by the routes, sorted and without duplicates
+func (c *Configuration) ForwardTopics() []string {
+	found := make(map[string]bool)
+	var topics []string
+	for _, origCollection := range c.Config {
+		for _, val := range origCollection {
+			if val.ForwardTopic != "" && !found[val.ForwardTopic] {
+				found[val.ForwardTopic] = true
+				topics = append(topics, val.ForwardTopic)
+			}
 		}
 	}
-	return "", errors.New("origin system and content type not configured")
+	sort.Strings(topics)
+	return topics
 }
 
-// ReadConfigFromReader reads config as a json stream from the given reader
+// ReadConfigFromReader reads config as a json stream from the given reader.
+// All the problems of the routes are returned at once as ValidationErrors.
 func ReadConfigFromReader(r io.Reader) (c *Configuration, e error) {
+	data, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
 	c = new(Configuration)
-	decoder := json.NewDecoder(r)
-	e = decoder.Decode(&c.Config)
+	e = json.Unmarshal(data, &c.Config)
 	if e != nil {
 		return nil, e
 	}
-	return c, c.validateConfig()
+
+	v := &validator{}
+	v.checkUnknownFields(data)
+	if err := c.validateConfig(); err != nil {
+		v.errs = append(v.errs, err.(ValidationErrors)...)
+	}
+	return c, v.err()
 }
 
 // ReadConfig reads config as a json file from the given path
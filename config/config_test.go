@@ -1,7 +1,10 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -10,10 +13,16 @@ func toString(c *Configuration) string {
 	if c == nil {
 		return ""
 	}
+	keys := make([]string, 0, len(c.Config))
+	for oKey := range c.Config {
+		keys = append(keys, oKey)
+	}
+	sort.Strings(keys)
+
 	var str string
-	for oKey, origCollection := range c.Config {
+	for _, oKey := range keys {
 		str += oKey
-		for _, val := range origCollection {
+		for _, val := range c.Config[oKey] {
 			str += val.ContentType + val.Collection
 		}
 	}
@@ -50,7 +59,7 @@ func TestValidateConfig(t *testing.T) {
 					},
 				},
 			},
-			errors.New("contentType value is mandatory"),
+			errors.New(`config["http://cmdb.ft.com/systems/methode-web-pub"][0].content_type: contentType value is mandatory`),
 		},
 		{
 			"Empty Collection",
@@ -63,7 +72,7 @@ func TestValidateConfig(t *testing.T) {
 					},
 				},
 			},
-			errors.New("collection value is mandatory"),
+			errors.New(`config["http://cmdb.ft.com/systems/methode-web-pub"][0].collection: collection value is mandatory`),
 		},
 	}
 	for _, tt := range tests {
@@ -429,3 +438,234 @@ func TestConfigurationMetadata_GetCollection(t *testing.T) {
 		})
 	}
 }
+
+func TestReadConfigWithUUIDFields(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_fields": ["post.id", "identifiers[0].value"]}
+		],
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "collection": "methode"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ReadConfigFromReader() error = %v", err)
+	}
+
+	route, err := c.GetRoute("http://cmdb.ft.com/systems/wordpress", "application/json")
+	if err != nil {
+		t.Fatalf("GetRoute() error = %v", err)
+	}
+	if len(route.UUIDPaths()) != 2 || route.UUIDPaths()[0].String() != "post.id" || route.UUIDPaths()[1].String() != "identifiers[0].value" {
+		t.Errorf("UUIDPaths() = %v, want [post.id identifiers[0].value]", route.UUIDPaths())
+	}
+
+	route, err = c.GetRoute("http://cmdb.ft.com/systems/methode-web-pub", "application/json")
+	if err != nil {
+		t.Fatalf("GetRoute() error = %v", err)
+	}
+	if len(route.UUIDPaths()) != 0 {
+		t.Errorf("UUIDPaths() = %v, want none", route.UUIDPaths())
+	}
+}
+
+func TestReadConfigWithInvalidUUIDFields(t *testing.T) {
+	_, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_fields": ["post[0"]}
+		]
+	}`))
+	if err == nil {
+		t.Error("ReadConfigFromReader() should fail with an invalid UUID field")
+	}
+}
+
+func TestUUIDDerivation(t *testing.T) {
+	tests := []struct {
+		name       string
+		derivation UUIDDerivation
+		body       string
+		expUUID    string
+		expFound   bool
+	}{
+		{"version 3 with named namespace", UUIDDerivation{Version: 3, Namespace: "url", Field: "webUrl"}, `{"webUrl": "https://www.ft.com/content/12345"}`, "eed1fc2d-9522-3c49-b135-ee546764432f", true},
+		{"version 5 from number", UUIDDerivation{Version: 5, Namespace: "2f7b1d8e-3c1a-4c1e-9a51-0c6b8f1e4d2a", Field: "$.post.id"}, `{"post": {"id": 12345}}`, "1894f6cf-cb57-59d8-a7b7-da9922306499", true},
+		{"version 5 from string", UUIDDerivation{Version: 5, Namespace: "2f7b1d8e-3c1a-4c1e-9a51-0c6b8f1e4d2a", Field: "id"}, `{"id": "12345"}`, "1894f6cf-cb57-59d8-a7b7-da9922306499", true},
+		{"version 5 from large number", UUIDDerivation{Version: 5, Namespace: "url", Field: "id"}, `{"id": 12345678901234567890}`, "cf0d89fb-e9ee-53ab-b85b-f69490eba4cf", true},
+		{"missing field", UUIDDerivation{Version: 5, Namespace: "url", Field: "id"}, `{"other": "12345"}`, "", false},
+		{"empty field", UUIDDerivation{Version: 5, Namespace: "url", Field: "id"}, `{"id": ""}`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &validator{}
+			tt.derivation.check(v, "uuid_derivation")
+			if err := v.err(); err != nil {
+				t.Fatalf("check() error = %v", err)
+			}
+			var body interface{}
+			decoder := json.NewDecoder(strings.NewReader(tt.body))
+			decoder.UseNumber()
+			if err := decoder.Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			uuid, found := tt.derivation.Derive(body)
+			if uuid != tt.expUUID || found != tt.expFound {
+				t.Errorf("Derive() = (%v, %v), want (%v, %v)", uuid, found, tt.expUUID, tt.expFound)
+			}
+		})
+	}
+}
+
+func TestReadConfigWithInvalidUUIDDerivation(t *testing.T) {
+	tests := []struct {
+		name       string
+		derivation string
+	}{
+		{"unsupported version", `{"version": 4, "namespace": "url", "field": "id"}`},
+		{"invalid namespace", `{"version": 5, "namespace": "ft", "field": "id"}`},
+		{"invalid field", `{"version": 5, "namespace": "url", "field": "items[0"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadConfigFromReader(strings.NewReader(`{
+				"http://cmdb.ft.com/systems/wordpress": [
+					{"content_type": ".*", "collection": "wordpress", "uuid_derivation": ` + tt.derivation + `}
+				]
+			}`))
+			if err == nil {
+				t.Error("ReadConfigFromReader() should fail with an invalid UUID derivation")
+			}
+		})
+	}
+}
+
+func TestReadConfigWithInvalidUUIDVersions(t *testing.T) {
+	_, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_versions": [4, 6]}
+		]
+	}`))
+	if err == nil {
+		t.Error("ReadConfigFromReader() should fail with an invalid UUID version")
+	}
+}
+
+func TestReadConfigWithUUIDXMLFields(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": "application/xml", "collection": "methode", "uuid_xml_fields": ["/article/@uuid", "//uuid"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ReadConfigFromReader() error = %v", err)
+	}
+	route, err := c.GetRoute("http://cmdb.ft.com/systems/methode-web-pub", "application/xml")
+	if err != nil {
+		t.Fatalf("GetRoute() error = %v", err)
+	}
+	if len(route.UUIDXPaths()) != 2 || route.UUIDXPaths()[1].String() != "//uuid" {
+		t.Errorf("UUIDXPaths() = %v, want the compiled uuid_xml_fields", route.UUIDXPaths())
+	}
+
+	_, err = ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "collection": "methode", "uuid_xml_fields": ["/article["]}
+		]
+	}`))
+	if err == nil {
+		t.Error("ReadConfigFromReader() should fail with an invalid UUID XML field")
+	}
+}
+
+func TestGetCollectionWithCatchAllRoutes(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": "*", "collection": "wordpress-quarantine"},
+			{"content_type": "^application/json", "collection": "wordpress"},
+			{"content_type": "^application/xml", "skip": true}
+		],
+		"http://cmdb.ft.com/systems/cct": [
+			{"content_type": "^application/json", "collection": "universal-content"}
+		],
+		"*": [
+			{"content_type": "^application/vnd.ft-upp-legacy", "skip": true},
+			{"content_type": "*", "collection": "quarantine"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ReadConfigFromReader() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		originID    string
+		contentType string
+		want        string
+		wantErr     error
+	}{
+		{"matching route before the fallback", "http://cmdb.ft.com/systems/wordpress", "application/json", "wordpress", nil},
+		{"fallback of the origin system", "http://cmdb.ft.com/systems/wordpress", "text/plain", "wordpress-quarantine", nil},
+		{"skipped route", "http://cmdb.ft.com/systems/wordpress", "application/xml", "", ErrRouteSkipped},
+		{"catch-all origin for a content type not routed", "http://cmdb.ft.com/systems/cct", "text/plain", "quarantine", nil},
+		{"catch-all origin for an unknown origin", "http://cmdb.ft.com/systems/unknown", "application/json", "quarantine", nil},
+		{"skipped route of the catch-all origin", "http://cmdb.ft.com/systems/unknown", "application/vnd.ft-upp-legacy+json", "", ErrRouteSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetCollection(tt.originID, tt.contentType)
+			if err != tt.wantErr {
+				t.Errorf("GetCollection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetCollection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigWithInvalidCatchAllRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes string
+	}{
+		{"two fallback routes", `[{"content_type": "*", "collection": "a"}, {"content_type": "*", "collection": "b"}]`},
+		{"skipped route with a collection", `[{"content_type": ".*", "collection": "a", "skip": true}]`},
+		{"route without collection", `[{"content_type": ".*"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadConfigFromReader(strings.NewReader(`{"*": ` + tt.routes + `}`))
+			if err == nil {
+				t.Error("ReadConfigFromReader() should fail")
+			}
+		})
+	}
+}
+
+func TestForwardTopics(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/next-video-editor": [
+			{"content_type": "^application/json", "collection": "video", "forward_topic": "NativeVideoEvents"},
+			{"content_type": ".*", "collection": "universal-content", "skip_forward": true}
+		],
+		"http://cmdb.ft.com/systems/spark": [
+			{"content_type": "^application/json", "collection": "universal-content", "forward_topic": "NativeVideoEvents"},
+			{"content_type": ".*", "collection": "universal-content", "forward_topic": "NativeAudioEvents"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ReadConfigFromReader() error = %v", err)
+	}
+	if got := c.ForwardTopics(); !reflect.DeepEqual(got, []string{"NativeAudioEvents", "NativeVideoEvents"}) {
+		t.Errorf("ForwardTopics() = %v", got)
+	}
+
+	_, err = ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/spark": [
+			{"content_type": ".*", "collection": "universal-content", "forward_topic": "NativeAudioEvents", "skip_forward": true}
+		]
+	}`))
+	if err == nil {
+		t.Error("ReadConfigFromReader() should fail when a route both skips forwarding and has a forward topic")
+	}
+}
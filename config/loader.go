@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+)
+
+// Provider gives access to the configuration currently in use
+type Provider interface {
+	Current() *Configuration
+}
+
+// Current returns the configuration itself, so that a configuration that never changes can be used as a Provider
+func (c *Configuration) Current() *Configuration {
+	return c
+}
+
+// Loader holds the configuration read from a file and reloads it when asked to.
+// A new configuration is swapped in only when it is valid, otherwise the previous one is kept.
+type Loader struct {
+	path    string
+	current atomic.Value
+	mutex   sync.Mutex
+	modTime time.Time
+	lastErr error
+}
+
+// NewLoader reads the configuration from the given path, failing if it is not valid
+func NewLoader(path string) (*Loader, error) {
+	l := &Loader{path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Current returns the latest valid configuration
+func (l *Loader) Current() *Configuration {
+	return l.current.Load().(*Configuration)
+}
+
+// Reload reads the configuration file again and swaps it in if it is valid
+func (l *Loader) Reload() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	info, err := os.Stat(l.path)
+	if err == nil {
+		l.modTime = info.ModTime()
+	}
+
+	c, err := ReadConfig(l.path)
+	if err != nil {
+		l.lastErr = err
+		return err
+	}
+	l.current.Store(c)
+	l.lastErr = nil
+	return nil
+}
+
+// Watch reloads the configuration every time the modification time of the file changes, checking it at the given interval.
+// It returns when the stop channel is closed.
+func (l *Loader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if l.changed() {
+				l.ReloadAndLog("file changed")
+			}
+		}
+	}
+}
+
+// ReloadAndLog reloads the configuration and logs the outcome, giving the reason of the reload
+func (l *Loader) ReloadAndLog(reason string) {
+	if err := l.Reload(); err != nil {
+		logger.Errorf(map[string]interface{}{"config": l.path}, err, "Invalid configuration, keeping the previous one (reload reason: %v)", reason)
+		return
+	}
+	logger.Infof(map[string]interface{}{"config": l.path}, "Reloaded configuration (reload reason: %v)", reason)
+}
+
+// changed returns true if the file has been modified since it was last read, or cannot be found
+func (l *Loader) changed() bool {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return !info.ModTime().Equal(l.modTime)
+}
+
+// Check reports whether the last attempt to load the configuration failed
+func (l *Loader) Check() (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.lastErr != nil {
+		return fmt.Sprintf("Configuration %v could not be reloaded, the previous one is still in use", l.path), l.lastErr
+	}
+	return "Configuration is up to date", nil
+}
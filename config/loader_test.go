@@ -0,0 +1,144 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const methodeConfig = `{
+	"http://cmdb.ft.com/systems/methode-web-pub": [
+		{
+			"content_type": ".*",
+			"collection": "methode"
+		}
+	]
+}`
+
+const sparkConfig = `{
+	"http://cmdb.ft.com/systems/methode-web-pub": [
+		{
+			"content_type": ".*",
+			"collection": "methode"
+		}
+	],
+	"http://cmdb.ft.com/systems/spark": [
+		{
+			"content_type": ".*",
+			"collection": "universal-content"
+		}
+	]
+}`
+
+const invalidConfig = `{
+	"http://cmdb.ft.com/systems/spark": [
+		{
+			"content_type": "",
+			"collection": "universal-content"
+		}
+	]
+}`
+
+func init() {
+	logger.InitDefaultLogger("native-ingester")
+}
+
+func writeConfigFile(t *testing.T, path string, content string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func newTestLoader(t *testing.T) (*Loader, string) {
+	dir, err := ioutil.TempDir("", "native-ingester-config")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "config.json")
+	writeConfigFile(t, path, methodeConfig)
+
+	l, err := NewLoader(path)
+	require.NoError(t, err)
+	return l, path
+}
+
+func TestNewLoaderFailsWithInvalidConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeConfigFile(t, path, invalidConfig)
+
+	_, err = NewLoader(path)
+	assert.EqualError(t, err, `config["http://cmdb.ft.com/systems/spark"][0].content_type: contentType value is mandatory`)
+
+	_, err = NewLoader(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestLoaderReloadsValidConfig(t *testing.T) {
+	l, path := newTestLoader(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := l.Current().GetCollection("http://cmdb.ft.com/systems/spark", "application/json")
+	assert.EqualError(t, err, "origin system not found")
+
+	writeConfigFile(t, path, sparkConfig)
+	assert.NoError(t, l.Reload())
+
+	collection, err := l.Current().GetCollection("http://cmdb.ft.com/systems/spark", "application/json")
+	assert.NoError(t, err)
+	assert.Equal(t, "universal-content", collection)
+
+	_, err = l.Check()
+	assert.NoError(t, err)
+}
+
+func TestLoaderKeepsPreviousConfigWhenInvalid(t *testing.T) {
+	l, path := newTestLoader(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	previous := l.Current()
+
+	writeConfigFile(t, path, invalidConfig)
+	assert.EqualError(t, l.Reload(), `config["http://cmdb.ft.com/systems/spark"][0].content_type: contentType value is mandatory`)
+	assert.True(t, previous == l.Current(), "It should keep the previous configuration")
+
+	_, err := l.Check()
+	assert.EqualError(t, err, `config["http://cmdb.ft.com/systems/spark"][0].content_type: contentType value is mandatory`)
+
+	writeConfigFile(t, path, sparkConfig)
+	assert.NoError(t, l.Reload())
+	_, err = l.Check()
+	assert.NoError(t, err, "It should recover once the file is valid again")
+}
+
+func TestLoaderWatchReloadsChangedFile(t *testing.T) {
+	l, path := newTestLoader(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go l.Watch(10*time.Millisecond, stop)
+
+	writeConfigFile(t, path, sparkConfig)
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	var err error
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err = l.Current().GetCollection("http://cmdb.ft.com/systems/spark", "application/json"); err == nil {
+			break
+		}
+	}
+	assert.NoError(t, err, "It should reload the changed file")
+}
+
+func TestStaticConfigurationIsProvider(t *testing.T) {
+	c, err := ReadConfigFromReader(strings.NewReader(methodeConfig))
+	require.NoError(t, err)
+
+	var p Provider = c
+	assert.True(t, c == p.Current())
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is a problem of the configuration, found at the JSON path of the faulty value,
+// such as config["http://cmdb.ft.com/systems/spark"][1].content_type
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+// ValidationErrors are all the problems found while validating a configuration
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	problems := make([]string, 0, len(e))
+	for _, err := range e {
+		problems = append(problems, err.Error())
+	}
+	return fmt.Sprintf("%v problems in the configuration: %v", len(e), strings.Join(problems, "; "))
+}
+
+// validator collects the problems of a configuration
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) addf(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems found, or nil if there are none
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func originPath(origin string) string {
+	return "config[" + strconv.Quote(origin) + "]"
+}
+
+func routePath(origin string, index int) string {
+	return fmt.Sprintf("%v[%v]", originPath(origin), index)
+}
+
+// sortedOrigins returns the origin systems of the configuration, so that problems are reported in a stable order
+func sortedOrigins(config map[string][]OriginSystemConfig) []string {
+	origins := make([]string, 0, len(config))
+	for origin := range config {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// matchesAnything returns true if the regular expression matches any content type, like .* or ^(.*)$
+func matchesAnything(expr string) bool {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	re = re.Simplify()
+	for {
+		switch {
+		case re.Op == syntax.OpCapture:
+			re = re.Sub[0]
+			continue
+		case re.Op == syntax.OpConcat:
+			var subs []*syntax.Regexp
+			for _, sub := range re.Sub {
+				if sub.Op != syntax.OpBeginLine && sub.Op != syntax.OpBeginText && sub.Op != syntax.OpEndLine && sub.Op != syntax.OpEndText {
+					subs = append(subs, sub)
+				}
+			}
+			if len(subs) != 1 {
+				return false
+			}
+			re = subs[0]
+			continue
+		}
+		break
+	}
+	return re.Op == syntax.OpStar && (re.Sub[0].Op == syntax.OpAnyChar || re.Sub[0].Op == syntax.OpAnyCharNotNL)
+}
+
+// jsonFields returns the names of the JSON fields of a struct type
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+var (
+	routeFields          = jsonFields(reflect.TypeOf(OriginSystemConfig{}))
+	uuidDerivationFields = jsonFields(reflect.TypeOf(UUIDDerivation{}))
+)
+
+// checkUnknownFields reports the fields of the routes in the JSON configuration that are not known,
+// which are otherwise silently ignored, like a misspelt collection
+func (v *validator) checkUnknownFields(data []byte) {
+	var raw map[string][]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	origins := make([]string, 0, len(raw))
+	for origin := range raw {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+
+	for _, origin := range origins {
+		for i, route := range raw[origin] {
+			path := routePath(origin, i)
+			for _, field := range sortedKeys(route) {
+				if !routeFields[field] {
+					v.addf(path+"."+field, "unknown field")
+				}
+			}
+			var derivation map[string]json.RawMessage
+			if json.Unmarshal(route["uuid_derivation"], &derivation) == nil {
+				for _, field := range sortedKeys(derivation) {
+					if !uuidDerivationFields[field] {
+						v.addf(path+".uuid_derivation."+field, "unknown field")
+					}
+				}
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
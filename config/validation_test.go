@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigReportsAllProblems(t *testing.T) {
+	_, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/spark": [
+			{"content_type": "^application/json", "collection": "universal-content"},
+			{"content_type": "^application/(json", "collection": "universal-content"},
+			{"content_type": "^application/xml", "colection": "universal-content"}
+		],
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_versions": [6],
+				"uuid_derivation": {"version": 4, "namespace": "url", "field": "id", "prefix": "wp"}}
+		],
+		"": [
+			{"content_type": ".*", "collection": "methode"}
+		]
+	}`))
+
+	var problems ValidationErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("ReadConfigFromReader() error = %v, want ValidationErrors", err)
+	}
+	var paths []string
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	expected := []string{
+		`config["http://cmdb.ft.com/systems/spark"][2].colection`,
+		`config["http://cmdb.ft.com/systems/wordpress"][0].uuid_derivation.prefix`,
+		`config[""]`,
+		`config["http://cmdb.ft.com/systems/spark"][1].content_type`,
+		`config["http://cmdb.ft.com/systems/spark"][2].collection`,
+		`config["http://cmdb.ft.com/systems/wordpress"][0].uuid_versions[0]`,
+		`config["http://cmdb.ft.com/systems/wordpress"][0].uuid_derivation.version`,
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("ReadConfigFromReader() problems at %v, want %v", paths, expected)
+	}
+	if !strings.HasPrefix(err.Error(), "7 problems in the configuration: ") {
+		t.Errorf("ReadConfigFromReader() error = %v", err)
+	}
+}
+
+func TestReadConfigReportsInvalidRegexp(t *testing.T) {
+	_, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/spark": [
+			{"content_type": "^application/(json", "collection": "universal-content"}
+		]
+	}`))
+	expected := "config[\"http://cmdb.ft.com/systems/spark\"][0].content_type: invalid regular expression: error parsing regexp: missing closing ): `^application/(json`"
+	if err == nil || err.Error() != expected {
+		t.Errorf("ReadConfigFromReader() error = %v, want %v", err, expected)
+	}
+}
+
+func TestReadConfigReportsDuplicateAndShadowedRoutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		routes   string
+		expected string
+	}{
+		{
+			"duplicate content type",
+			`[{"content_type": "^application/json", "collection": "a"}, {"content_type": "^application/json", "collection": "b"}]`,
+			`config["http://cmdb.ft.com/systems/spark"][1]: duplicates route [0], content type "^application/json"`,
+		},
+		{
+			"two fallback routes",
+			`[{"content_type": "*", "collection": "a"}, {"content_type": "*", "collection": "b"}]`,
+			`config["http://cmdb.ft.com/systems/spark"][1]: duplicates route [0], content type "*"`,
+		},
+		{
+			"route after one matching anything",
+			`[{"content_type": "^(.*)$", "collection": "a"}, {"content_type": "^application/json", "collection": "b"}]`,
+			`config["http://cmdb.ft.com/systems/spark"][1]: is shadowed by route [0], whose content type "^(.*)$" matches any content type`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadConfigFromReader(strings.NewReader(`{"http://cmdb.ft.com/systems/spark": ` + tt.routes + `}`))
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("ReadConfigFromReader() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadConfigAcceptsFallbackAfterRouteMatchingAnything(t *testing.T) {
+	_, err := ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/spark": [
+			{"content_type": ".*", "collection": "universal-content"},
+			{"content_type": "*", "collection": "quarantine"}
+		]
+	}`))
+	if err != nil {
+		t.Errorf("ReadConfigFromReader() error = %v", err)
+	}
+}
+
+func TestReadConfigReportsOriginWithoutRoutes(t *testing.T) {
+	_, err := ReadConfigFromReader(strings.NewReader(`{"http://cmdb.ft.com/systems/spark": []}`))
+	expected := `config["http://cmdb.ft.com/systems/spark"]: origin system has no routes`
+	if err == nil || err.Error() != expected {
+		t.Errorf("ReadConfigFromReader() error = %v, want %v", err, expected)
+	}
+}
+
+func TestMatchesAnything(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{".*", true},
+		{"^.*$", true},
+		{"^(.*)$", true},
+		{"(?s).*", true},
+		{".+", false},
+		{"^application/.*", false},
+		{"application/json", false},
+		{"*", false},
+		{"(", false},
+	}
+	for _, tt := range tests {
+		if got := matchesAnything(tt.expr); got != tt.expected {
+			t.Errorf("matchesAnything(%q) = %v, want %v", tt.expr, got, tt.expected)
+		}
+	}
+}
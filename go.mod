@@ -5,27 +5,21 @@ go 1.13
 require (
 	github.com/Financial-Times/go-fthealth v0.0.0-20171204124831-1b007e2b37b7
 	github.com/Financial-Times/go-logger v0.0.0-20170914081945-83fc3e64dc55
-	github.com/Financial-Times/kafka v0.0.0-20181214115819-fddecb2b8f89 // indirect
+	github.com/Financial-Times/kafka v0.0.0-20181214115819-fddecb2b8f89
 	github.com/Financial-Times/kafka-client-go v0.0.0-20181214120216-c3a1941e42a4
 	github.com/Financial-Times/service-status-go v0.0.0-20160323111542-3f5199736a3d
-	github.com/Shopify/sarama v1.23.1 // indirect
+	github.com/Shopify/sarama v1.23.1
 	github.com/gorilla/context v1.1.1 // indirect
 	github.com/gorilla/mux v1.3.0
 	github.com/hashicorp/go-version v0.0.0-20170202080759-03c5bf6be031 // indirect
 	github.com/jawher/mow.cli v0.0.0-20170220225154-d3ffbc2f98b8
-	github.com/jmoiron/jsonq v0.0.0-20150511023944-e874b168d07e
-	github.com/onsi/ginkgo v1.10.2 // indirect
-	github.com/onsi/gomega v1.7.0 // indirect
 	github.com/pierrec/lz4 v1.0.2-0.20171218195038-2fcda4cb7018 // indirect
 	github.com/pierrec/xxHash v0.1.1 // indirect
-	github.com/pkg/errors v0.8.0 // indirect
+	github.com/prometheus/client_golang v1.2.1
 	github.com/samuel/go-zookeeper v0.0.0-20180130194729-c4fab1ac1bec // indirect
 	github.com/satori/go.uuid v1.1.0
-	github.com/sirupsen/logrus v1.0.5 // indirect
 	github.com/stretchr/testify v1.3.0
-	github.com/wvanbergen/kazoo-go v0.0.0-20180202103751-f72d8611297a // indirect
+	github.com/wvanbergen/kazoo-go v0.0.0-20180202103751-f72d8611297a
 	golang.org/x/time v0.0.0-20190921001708-c4c64cad1fd0 // indirect
-	gopkg.in/airbrake/gobrake.v2 v2.0.9 // indirect
-	gopkg.in/gemnasium/logrus-airbrake-hook.v2 v2.1.2 // indirect
 	gopkg.in/jcmturner/goidentity.v3 v3.0.0 // indirect
 )
@@ -0,0 +1,287 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// expression is a filter expression, tested against each child of the filtered node
+type expression interface {
+	test(current interface{}, root interface{}) bool
+}
+
+// operand is a value in a filter expression, which may be missing when it is a path matching nothing
+type operand interface {
+	value(current interface{}, root interface{}) (interface{}, bool)
+}
+
+type orExpression []expression
+
+func (e orExpression) test(current interface{}, root interface{}) bool {
+	for _, sub := range e {
+		if sub.test(current, root) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpression []expression
+
+func (e andExpression) test(current interface{}, root interface{}) bool {
+	for _, sub := range e {
+		if !sub.test(current, root) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpression struct {
+	expression
+}
+
+func (e notExpression) test(current interface{}, root interface{}) bool {
+	return !e.expression.test(current, root)
+}
+
+// existsExpression is true when its operand is a path matching a value
+type existsExpression struct {
+	operand
+}
+
+func (e existsExpression) test(current interface{}, root interface{}) bool {
+	_, found := e.value(current, root)
+	return found
+}
+
+type comparison struct {
+	left, right operand
+	operator    string
+}
+
+func (c comparison) test(current interface{}, root interface{}) bool {
+	left, leftFound := c.left.value(current, root)
+	right, rightFound := c.right.value(current, root)
+	left, right = numberValue(left), numberValue(right)
+	if !leftFound || !rightFound {
+		switch c.operator {
+		case "==":
+			return leftFound == rightFound
+		case "!=":
+			return leftFound != rightFound
+		default:
+			return false
+		}
+	}
+
+	switch c.operator {
+	case "==":
+		return reflect.DeepEqual(left, right)
+	case "!=":
+		return !reflect.DeepEqual(left, right)
+	}
+
+	var order int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case l < r:
+			order = -1
+		case l > r:
+			order = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		order = strings.Compare(l, r)
+	default:
+		return false
+	}
+
+	switch c.operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// numberValue returns the numbers of documents decoded with json.Number as float64, so that they compare with literals
+func numberValue(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// pathOperand is the first value matched by a path, relative to the current node (@) or to the root ($)
+type pathOperand struct {
+	relative bool
+	steps    []step
+}
+
+func (o pathOperand) value(current interface{}, root interface{}) (interface{}, bool) {
+	start := root
+	if o.relative {
+		start = current
+	}
+	values := evaluate(o.steps, start, root)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+type literalOperand struct {
+	literal interface{}
+}
+
+func (o literalOperand) value(current interface{}, root interface{}) (interface{}, bool) {
+	return o.literal, true
+}
+
+// comparisonOperators are sorted so that two-character operators are matched first
+var comparisonOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *parser) parseOr() (expression, error) {
+	var or orExpression
+	for {
+		and, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, and)
+		p.skipSpaces()
+		if !strings.HasPrefix(p.expr[p.pos:], "||") {
+			break
+		}
+		p.pos += 2
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	var and andExpression
+	for {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, e)
+		p.skipSpaces()
+		if !strings.HasPrefix(p.expr[p.pos:], "&&") {
+			break
+		}
+		p.pos += 2
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	p.skipSpaces()
+	if p.done() {
+		return nil, p.errorf("expected a filter expression")
+	}
+	switch p.peek() {
+	case '!':
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{e}, nil
+	case '(':
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return e, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *parser) parseComparison() (expression, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, operator := range comparisonOperators {
+		if strings.HasPrefix(p.expr[p.pos:], operator) {
+			p.pos += len(operator)
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return comparison{left: left, right: right, operator: operator}, nil
+		}
+	}
+	return existsExpression{left}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	p.skipSpaces()
+	if p.done() {
+		return nil, p.errorf("expected a value")
+	}
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		steps, err := p.parseSegments(true)
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{relative: c == '@', steps: steps}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalOperand{s}, nil
+	case c == '-' || isDigit(c):
+		start := p.pos
+		for !p.done() && strings.IndexByte("+-.eE0123456789", p.peek()) >= 0 {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.expr[start:p.pos])
+		}
+		return literalOperand{f}, nil
+	}
+
+	for keyword, literal := range map[string]interface{}{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(p.expr[p.pos:], keyword) {
+			p.pos += len(keyword)
+			return literalOperand{literal}, nil
+		}
+	}
+	return nil, p.errorf("unexpected character %q", p.peek())
+}
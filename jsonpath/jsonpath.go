@@ -0,0 +1,102 @@
+// Package jsonpath evaluates JSONPath expressions against JSON documents decoded by encoding/json.
+//
+// Besides the usual syntax, such as $.items[0].id, $['key.with.dots'], $.items[*].id, $..id or
+// $.identifiers[?(@.authority == 'x')].value, the leading $ can be omitted and array elements
+// can be selected with dotted numbers, so that plain dotted paths like post.uuid or items.0.id keep working.
+//
+// It replaces jsonq, which only handled those dotted paths, rather than a JSONPath library: github.com/PaesslerAG/jsonpath
+// rejects dotted numbers and returns the values of an object in random order, which would change the UUID found first
+// by the configured uuid_fields. Filters compare the json.Number values of bodies decoded without rounding.
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Path is a compiled JSONPath expression
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// step applies a selector to the current nodes, or to the current nodes and all their descendants if recursive
+type step struct {
+	recursive bool
+	selector  selector
+}
+
+type selector interface {
+	selectFrom(node interface{}, root interface{}) []interface{}
+}
+
+// Compile parses a JSONPath expression
+func Compile(expr string) (*Path, error) {
+	p := &parser{expr: expr}
+	steps, err := p.parsePath()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %v", expr, err)
+	}
+	return &Path{expr: expr, steps: steps}, nil
+}
+
+// String returns the expression the path was compiled from
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find returns the values matched by the path in the document, in document order
+func (p *Path) Find(doc interface{}) []interface{} {
+	return evaluate(p.steps, doc, doc)
+}
+
+func evaluate(steps []step, node interface{}, root interface{}) []interface{} {
+	nodes := []interface{}{node}
+	for _, s := range steps {
+		var next []interface{}
+		for _, n := range nodes {
+			if s.recursive {
+				for _, d := range descendants(n) {
+					next = append(next, s.selector.selectFrom(d, root)...)
+				}
+			} else {
+				next = append(next, s.selector.selectFrom(n, root)...)
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants returns the node followed by all its descendants, depth first
+func descendants(node interface{}) []interface{} {
+	result := []interface{}{node}
+	for _, child := range children(node) {
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
+
+// children returns the elements of an array or the values of an object, sorted by key
+func children(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case []interface{}:
+		return n
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(n))
+		for _, k := range keys {
+			values = append(values, n[k])
+		}
+		return values
+	default:
+		return nil
+	}
+}
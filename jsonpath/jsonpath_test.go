@@ -0,0 +1,115 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDocument = `{
+	"uuid": "07ac9fad-6434-47c7-b7c4-34361a048d07",
+	"post": {"uuid": "b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11"},
+	"key.with.dots": "dotted",
+	"items": [
+		{"id": "first", "rank": 1},
+		{"id": "second", "rank": 2},
+		{"id": "third", "rank": 3, "hidden": true}
+	],
+	"identifiers": [
+		{"authority": "http://api.ft.com/system/FTCOM-METHODE", "value": "methode-id"},
+		{"authority": "http://api.ft.com/system/WORDPRESS", "value": "wordpress-id"}
+	],
+	"nested": {"deeper": {"id": "deep"}},
+	"default": "fallback"
+}`
+
+func TestFind(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(testDocument), &doc))
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected []interface{}
+	}{
+		{"dotted name", "uuid", []interface{}{"07ac9fad-6434-47c7-b7c4-34361a048d07"}},
+		{"dotted path", "post.uuid", []interface{}{"b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11"}},
+		{"dotted index", "items.1.id", []interface{}{"second"}},
+		{"root", "$.uuid", []interface{}{"07ac9fad-6434-47c7-b7c4-34361a048d07"}},
+		{"root and brackets", "$['post']['uuid']", []interface{}{"b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11"}},
+		{"quoted key with dots", `$["key.with.dots"]`, []interface{}{"dotted"}},
+		{"array index", "items[0].id", []interface{}{"first"}},
+		{"negative array index", "$.items[-1].id", []interface{}{"third"}},
+		{"wildcard", "$.items[*].id", []interface{}{"first", "second", "third"}},
+		{"dotted wildcard", "$.items.*.rank", []interface{}{1.0, 2.0, 3.0}},
+		{"union", "$.items[0,2].id", []interface{}{"first", "third"}},
+		{"slice", "$.items[1:].id", []interface{}{"second", "third"}},
+		{"slice with step", "$.items[::2].id", []interface{}{"first", "third"}},
+		{"reverse slice", "$.items[::-1].id", []interface{}{"third", "second", "first"}},
+		{"recursive descent", "$..deeper.id", []interface{}{"deep"}},
+		{"filter on string", "$.identifiers[?(@.authority == 'http://api.ft.com/system/WORDPRESS')].value", []interface{}{"wordpress-id"}},
+		{"filter with double quotes", `identifiers[?(@.authority=="http://api.ft.com/system/FTCOM-METHODE")].value`, []interface{}{"methode-id"}},
+		{"filter on number", "$.items[?(@.rank > 1)].id", []interface{}{"second", "third"}},
+		{"filter on existence", "$.items[?(@.hidden)].id", []interface{}{"third"}},
+		{"filter with negation", "$.items[?(!@.hidden)].id", []interface{}{"first", "second"}},
+		{"filter with boolean operators", "$.items[?(@.rank == 1 || (@.rank >= 2 && @.hidden == true))].id", []interface{}{"first", "third"}},
+		{"filter against root", "$.items[?(@.id == $.nested.deeper.id)]", nil},
+		{"missing member", "post.id", nil},
+		{"index out of range", "items[5]", nil},
+		{"name on a string", "uuid.id", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p.Find(doc))
+		})
+	}
+}
+
+func TestCompileInvalidExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ""},
+		{"recursive descent without name", "post.."},
+		{"trailing dot", "post."},
+		{"unclosed bracket", "items[0"},
+		{"unterminated string", "$['post]"},
+		{"invalid bracket", "items[abc]"},
+		{"unclosed filter", "items[?(@.rank > 1]"},
+		{"invalid filter value", "items[?(@.rank > foo)]"},
+		{"zero slice step", "items[::0]"},
+		{"garbage after root", "$uuid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	p, err := Compile("post.uuid")
+	require.NoError(t, err)
+	assert.Equal(t, "post.uuid", p.String())
+}
+
+func TestFindFiltersOnJSONNumbers(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(testDocument))
+	decoder.UseNumber()
+	var doc interface{}
+	require.NoError(t, decoder.Decode(&doc))
+
+	greater, err := Compile("$.items[?(@.rank > 1)].id")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"second", "third"}, greater.Find(doc))
+	equal, err := Compile("$.items[?(@.rank == 1)].id")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"first"}, equal.Find(doc))
+}
@@ -0,0 +1,289 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filterDelimiters end a name inside a filter expression, besides the characters ending any name
+const filterDelimiters = " \t=!<>()&|,]"
+
+type parser struct {
+	expr string
+	pos  int
+}
+
+func (p *parser) parsePath() ([]step, error) {
+	if p.expr == "" {
+		return nil, errors.New("empty expression")
+	}
+
+	var steps []step
+	switch p.expr[0] {
+	case '$':
+		p.pos++
+	case '.', '[':
+	default:
+		// a path without the leading $ starts with a name, like post.uuid
+		sel, err := p.parseName(false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step{selector: sel})
+	}
+
+	more, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+	return append(steps, more...), nil
+}
+
+// parseSegments parses the segments of a path until the end of the expression,
+// or until the end of the path inside a filter expression
+func (p *parser) parseSegments(inFilter bool) ([]step, error) {
+	var steps []step
+	for !p.done() {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			recursive := false
+			if !p.done() && p.peek() == '.' {
+				p.pos++
+				recursive = true
+			}
+			var sel selector
+			var err error
+			if recursive && !p.done() && p.peek() == '[' {
+				sel, err = p.parseBracket()
+			} else {
+				sel, err = p.parseName(inFilter)
+			}
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step{recursive: recursive, selector: sel})
+		case '[':
+			sel, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step{selector: sel})
+		default:
+			if inFilter {
+				return steps, nil
+			}
+			return nil, p.errorf("unexpected character %q", p.peek())
+		}
+	}
+	return steps, nil
+}
+
+func (p *parser) parseName(inFilter bool) (selector, error) {
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		if c == '.' || c == '[' || (inFilter && strings.IndexByte(filterDelimiters, c) >= 0) {
+			break
+		}
+		p.pos++
+	}
+	name := p.expr[start:p.pos]
+	if name == "" {
+		return nil, p.errorf("expected a name")
+	}
+	if name == "*" {
+		return wildcardSelector{}, nil
+	}
+	return nameSelector{name}, nil
+}
+
+// parseBracket parses a bracketed selector: a filter, or a union of names, indices, slices and wildcards
+func (p *parser) parseBracket() (selector, error) {
+	p.pos++ // [
+	p.skipSpaces()
+	if !p.done() && p.peek() == '?' {
+		p.pos++
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		return filterSelector{filter}, nil
+	}
+
+	var union unionSelector
+	for {
+		p.skipSpaces()
+		sel, err := p.parseBracketItem()
+		if err != nil {
+			return nil, err
+		}
+		union = append(union, sel)
+		p.skipSpaces()
+		if p.done() {
+			return nil, p.errorf("expected ']'")
+		}
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if len(union) == 1 {
+		return union[0], nil
+	}
+	return union, nil
+}
+
+func (p *parser) parseBracketItem() (selector, error) {
+	if p.done() {
+		return nil, p.errorf("expected a selector")
+	}
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector{name}, nil
+	case c == '-' || c == ':' || isDigit(c):
+		return p.parseIndexOrSlice()
+	default:
+		return nil, p.errorf("unexpected character %q", c)
+	}
+}
+
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	start, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.done() || p.peek() != ':' {
+		if start == nil {
+			return nil, p.errorf("expected an index")
+		}
+		return indexSelector{*start}, nil
+	}
+
+	p.pos++ // :
+	p.skipSpaces()
+	end, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+	slice := sliceSelector{start: start, end: end, step: 1}
+	p.skipSpaces()
+	if !p.done() && p.peek() == ':' {
+		p.pos++
+		p.skipSpaces()
+		step, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		if step != nil {
+			if *step == 0 {
+				return nil, p.errorf("slice step cannot be zero")
+			}
+			slice.step = *step
+		}
+	}
+	return slice, nil
+}
+
+// parseInt parses an optional integer, returning nil if there is none
+func (p *parser) parseInt() (*int, error) {
+	start := p.pos
+	if !p.done() && p.peek() == '-' {
+		p.pos++
+	}
+	for !p.done() && isDigit(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		return nil, p.errorf("invalid integer %q", p.expr[start:p.pos])
+	}
+	return &i, nil
+}
+
+// parseString parses a string quoted by single or double quotes, where a backslash escapes the next character
+func (p *parser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var sb strings.Builder
+	for !p.done() {
+		c := p.peek()
+		p.pos++
+		switch {
+		case c == quote:
+			return sb.String(), nil
+		case c == '\\' && !p.done():
+			escaped := p.peek()
+			p.pos++
+			switch escaped {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(escaped)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) expect(c byte) error {
+	if p.done() || p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) skipSpaces() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.expr)
+}
+
+func (p *parser) peek() byte {
+	return p.expr[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%v at position %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,128 @@
+package jsonpath
+
+import "strconv"
+
+// nameSelector selects the member of an object with the given name.
+// On arrays, a name made of digits selects the element at that index, as dotted paths like items.0.id expect.
+type nameSelector struct {
+	name string
+}
+
+func (s nameSelector) selectFrom(node interface{}, root interface{}) []interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if v, found := n[s.name]; found {
+			return []interface{}{v}
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(s.name); err == nil && i >= 0 {
+			return indexSelector{i}.selectFrom(node, root)
+		}
+	}
+	return nil
+}
+
+// indexSelector selects the element of an array at the given index, counting from the end when negative
+type indexSelector struct {
+	index int
+}
+
+func (s indexSelector) selectFrom(node interface{}, root interface{}) []interface{} {
+	array, ok := node.([]interface{})
+	if !ok {
+		return nil
+	}
+	i := s.index
+	if i < 0 {
+		i += len(array)
+	}
+	if i < 0 || i >= len(array) {
+		return nil
+	}
+	return []interface{}{array[i]}
+}
+
+// sliceSelector selects the elements of an array from start included to end excluded, with a step
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) selectFrom(node interface{}, root interface{}) []interface{} {
+	array, ok := node.([]interface{})
+	if !ok || s.step == 0 {
+		return nil
+	}
+	length := len(array)
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		v := *i
+		if v < 0 {
+			v += length
+		}
+		if v < 0 {
+			return 0
+		}
+		if v > length {
+			return length
+		}
+		return v
+	}
+
+	var result []interface{}
+	if s.step > 0 {
+		for i := bound(s.start, 0); i < bound(s.end, length); i += s.step {
+			result = append(result, array[i])
+		}
+		return result
+	}
+	start, end := length-1, -1
+	if s.start != nil {
+		start = bound(s.start, 0)
+		if start >= length {
+			start = length - 1
+		}
+	}
+	if s.end != nil {
+		end = bound(s.end, 0)
+	}
+	for i := start; i > end; i += s.step {
+		result = append(result, array[i])
+	}
+	return result
+}
+
+// wildcardSelector selects all the elements of an array or the values of an object
+type wildcardSelector struct{}
+
+func (wildcardSelector) selectFrom(node interface{}, root interface{}) []interface{} {
+	return children(node)
+}
+
+// unionSelector selects the values of all its selectors, in order
+type unionSelector []selector
+
+func (u unionSelector) selectFrom(node interface{}, root interface{}) []interface{} {
+	var result []interface{}
+	for _, s := range u {
+		result = append(result, s.selectFrom(node, root)...)
+	}
+	return result
+}
+
+// filterSelector selects the children of a node that match the filter expression
+type filterSelector struct {
+	filter expression
+}
+
+func (s filterSelector) selectFrom(node interface{}, root interface{}) []interface{} {
+	var result []interface{}
+	for _, child := range children(node) {
+		if s.filter.test(child, root) {
+			result = append(result, child)
+		}
+	}
+	return result
+}
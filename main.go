@@ -2,11 +2,13 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"github.com/Financial-Times/service-status-go/httphandlers"
 	"github.com/gorilla/mux"
 	cli "github.com/jawher/mow.cli"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -50,19 +53,182 @@ func main() {
 		Desc:   "The topic to read the messages from.",
 		EnvVar: "Q_READ_TOPIC",
 	})
+	workers := app.Int(cli.IntOpt{
+		Name:   "workers",
+		Value:  1,
+		Desc:   "Number of messages handled concurrently. Messages about the same content are always handled in order",
+		EnvVar: "WORKERS",
+	})
+	workerQueueDepth := app.Int(cli.IntOpt{
+		Name:   "worker-queue-depth",
+		Value:  10,
+		Desc:   "Number of messages queued by each worker before consumption is paused",
+		EnvVar: "WORKER_QUEUE_DEPTH",
+	})
+
 	// Native writer configuration
 	nativeWriterAddress := app.String(cli.StringOpt{
 		Name:   "native-writer-address",
 		Value:  "",
-		Desc:   "Address (URL) of service that writes persistently the native content",
+		Desc:   "Address (URL) of service that writes persistently the native content, or file://{directory} or mem:// to write it locally",
 		EnvVar: "NATIVE_RW_ADDRESS",
 	})
+	nativeWriterMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "native-writer-max-attempts",
+		Value:  3,
+		Desc:   "Maximum number of attempts to write a message in the native store when the native writer is unavailable",
+		EnvVar: "NATIVE_RW_MAX_ATTEMPTS",
+	})
+	nativeWriterRetryBaseDelay := app.String(cli.StringOpt{
+		Name:   "native-writer-retry-base-delay",
+		Value:  "200ms",
+		Desc:   "Delay before retrying a failed write in the native store, doubled on each retry",
+		EnvVar: "NATIVE_RW_RETRY_BASE_DELAY",
+	})
+	nativeWriterRetryMaxDelay := app.String(cli.StringOpt{
+		Name:   "native-writer-retry-max-delay",
+		Value:  "5s",
+		Desc:   "Maximum delay between two attempts to write in the native store",
+		EnvVar: "NATIVE_RW_RETRY_MAX_DELAY",
+	})
+	nativeWriterRetryJitter := app.String(cli.StringOpt{
+		Name:   "native-writer-retry-jitter",
+		Value:  "0.2",
+		Desc:   "Fraction (between 0 and 1) of the delay between two attempts to write in the native store that is randomised",
+		EnvVar: "NATIVE_RW_RETRY_JITTER",
+	})
+	nativeWriterConditionalWrites := app.Bool(cli.BoolOpt{
+		Name:   "native-writer-conditional-writes",
+		Value:  false,
+		Desc:   "Skip messages older than the content in the native store, by sending conditional writes to the native writer",
+		EnvVar: "NATIVE_RW_CONDITIONAL_WRITES",
+	})
+	nativeWriterTimeout := app.String(cli.StringOpt{
+		Name:   "native-writer-timeout",
+		Value:  "30s",
+		Desc:   "Maximum duration of each attempt to call the native writer, including reading the response, 0 for no timeout",
+		EnvVar: "NATIVE_RW_TIMEOUT",
+	})
+	nativeWriterDialTimeout := app.String(cli.StringOpt{
+		Name:   "native-writer-dial-timeout",
+		Value:  "5s",
+		Desc:   "Maximum duration to establish a connection to the native writer",
+		EnvVar: "NATIVE_RW_DIAL_TIMEOUT",
+	})
+	nativeWriterTLSHandshakeTimeout := app.String(cli.StringOpt{
+		Name:   "native-writer-tls-handshake-timeout",
+		Value:  "5s",
+		Desc:   "Maximum duration of the TLS handshake with the native writer",
+		EnvVar: "NATIVE_RW_TLS_HANDSHAKE_TIMEOUT",
+	})
+	nativeWriterResponseHeaderTimeout := app.String(cli.StringOpt{
+		Name:   "native-writer-response-header-timeout",
+		Value:  "15s",
+		Desc:   "Maximum duration to wait for the headers of the response of the native writer, 0 for no timeout",
+		EnvVar: "NATIVE_RW_RESPONSE_HEADER_TIMEOUT",
+	})
+	nativeWriterMaxIdleConns := app.Int(cli.IntOpt{
+		Name:   "native-writer-max-idle-conns",
+		Value:  100,
+		Desc:   "Maximum number of idle connections kept open, 0 for no limit",
+		EnvVar: "NATIVE_RW_MAX_IDLE_CONNS",
+	})
+	nativeWriterMaxIdleConnsPerHost := app.Int(cli.IntOpt{
+		Name:   "native-writer-max-idle-conns-per-host",
+		Value:  10,
+		Desc:   "Maximum number of idle connections kept open to the native writer",
+		EnvVar: "NATIVE_RW_MAX_IDLE_CONNS_PER_HOST",
+	})
+	nativeWriterIdleConnTimeout := app.String(cli.StringOpt{
+		Name:   "native-writer-idle-conn-timeout",
+		Value:  "90s",
+		Desc:   "How long an idle connection to the native writer is kept open, 0 for no limit",
+		EnvVar: "NATIVE_RW_IDLE_CONN_TIMEOUT",
+	})
+	nativeWriterKeepAlive := app.String(cli.StringOpt{
+		Name:   "native-writer-keep-alive",
+		Value:  "30s",
+		Desc:   "Keep-alive period of the connections to the native writer, 0 disables keep-alive and opens a connection per request",
+		EnvVar: "NATIVE_RW_KEEP_ALIVE",
+	})
+	nativeWriterCAFile := app.String(cli.StringOpt{
+		Name:   "native-writer-ca-file",
+		Value:  "",
+		Desc:   "PEM bundle of the CAs verifying the certificate of an https native writer address, instead of the system ones",
+		EnvVar: "NATIVE_RW_CA_FILE",
+	})
+	nativeWriterCertFile := app.String(cli.StringOpt{
+		Name:   "native-writer-cert-file",
+		Value:  "",
+		Desc:   "PEM client certificate presented to the native writer for mutual TLS, requires the key file",
+		EnvVar: "NATIVE_RW_CERT_FILE",
+	})
+	nativeWriterKeyFile := app.String(cli.StringOpt{
+		Name:   "native-writer-key-file",
+		Value:  "",
+		Desc:   "PEM key of the client certificate presented to the native writer",
+		EnvVar: "NATIVE_RW_KEY_FILE",
+	})
+	nativeWriterTokenFile := app.String(cli.StringOpt{
+		Name:   "native-writer-token-file",
+		Value:  "",
+		Desc:   "File holding the bearer token sent to the native writer, read again when it changes",
+		EnvVar: "NATIVE_RW_TOKEN_FILE",
+	})
+	nativeWriterShadowAddress := app.String(cli.StringOpt{
+		Name:   "native-writer-shadow-address",
+		Value:  "",
+		Desc:   "Address (URL) of a secondary native writer to which every write is repeated asynchronously and compared, none if empty",
+		EnvVar: "NATIVE_RW_SHADOW_ADDRESS",
+	})
+	nativeWriterShadowQueueDepth := app.Int(cli.IntOpt{
+		Name:   "native-writer-shadow-queue-depth",
+		Value:  1000,
+		Desc:   "Number of writes pending for the shadow native writer beyond which new ones are dropped",
+		EnvVar: "NATIVE_RW_SHADOW_QUEUE_DEPTH",
+	})
+	nativeWriterBreakerFailureRate := app.String(cli.StringOpt{
+		Name:   "native-writer-breaker-failure-rate",
+		Value:  "0",
+		Desc:   "Fraction (between 0 and 1) of failed writes in the native store that opens the circuit breaker and pauses consumption, 0 disables the circuit breaker",
+		EnvVar: "NATIVE_RW_BREAKER_FAILURE_RATE",
+	})
+	nativeWriterBreakerWindow := app.Int(cli.IntOpt{
+		Name:   "native-writer-breaker-window",
+		Value:  20,
+		Desc:   "Number of latest writes in the native store the failure rate of the circuit breaker is computed over",
+		EnvVar: "NATIVE_RW_BREAKER_WINDOW",
+	})
+	nativeWriterBreakerOpenDuration := app.String(cli.StringOpt{
+		Name:   "native-writer-breaker-open-duration",
+		Value:  "30s",
+		Desc:   "How long the circuit breaker stays open before probing the native writer",
+		EnvVar: "NATIVE_RW_BREAKER_OPEN_DURATION",
+	})
+	nativeWriterBreakerProbes := app.Int(cli.IntOpt{
+		Name:   "native-writer-breaker-probes",
+		Value:  1,
+		Desc:   "Number of successful probe writes in a row that close the circuit breaker",
+		EnvVar: "NATIVE_RW_BREAKER_PROBES",
+	})
+	dryRun := app.Bool(cli.BoolOpt{
+		Name:   "dry-run",
+		Value:  false,
+		Desc:   "Resolve the collection and UUID of the messages and log the calls to the native writer, without writing, forwarding or dead-lettering them",
+		EnvVar: "DRY_RUN",
+	})
 	contentUUIDfields := app.Strings(cli.StringsOpt{
 		Name:   "content-uuid-fields",
 		Value:  []string{},
-		Desc:   "List of JSONPaths that point to UUIDs in native content bodies. e.g. uuid,post.uuid,data.uuidv3",
+		Desc:   "List of JSONPaths that point to UUIDs in native content bodies. e.g. uuid,post.uuid,data.uuidv3,items[0].id",
 		EnvVar: "NATIVE_CONTENT_UUID_FIELDS",
 	})
+	contentUUIDXMLFields := app.Strings(cli.StringsOpt{
+		Name:   "content-uuid-xml-fields",
+		Value:  []string{},
+		Desc:   "List of XPaths that point to UUIDs in XML native content bodies. e.g. /article/@uuid,//identifier[@authority='x']",
+		EnvVar: "NATIVE_CONTENT_UUID_XML_FIELDS",
+	})
 	// Write Queue configuration
 	writeQueueAddress := app.String(cli.StringOpt{
 		Name:   "write-queue-address",
@@ -76,6 +242,12 @@ func main() {
 		Desc:   "The topic to write the messages to.",
 		EnvVar: "Q_WRITE_TOPIC",
 	})
+	deadLetterTopic := app.String(cli.StringOpt{
+		Name:   "dead-letter-topic",
+		Value:  "",
+		Desc:   "The topic to publish the messages that could not be ingested to (optional, requires the write queue address).",
+		EnvVar: "Q_DEAD_LETTER_TOPIC",
+	})
 	contentType := app.String(cli.StringOpt{
 		Name:   "content-type",
 		Value:  "",
@@ -94,6 +266,12 @@ func main() {
 		Desc:   "Config file (e.g. config.json)",
 		EnvVar: "CONFIG",
 	})
+	configReloadInterval := app.String(cli.StringOpt{
+		Name:   "config-reload-interval",
+		Value:  "30s",
+		Desc:   "How often to check the config file for changes, 0 disables the check. The config file is also reloaded on SIGHUP",
+		EnvVar: "CONFIG_RELOAD_INTERVAL",
+	})
 	panicGuideUrl := app.String(cli.StringOpt{
 		Name:   "panic-guide",
 		Value:  "",
@@ -101,39 +279,122 @@ func main() {
 		EnvVar: "PANIC_GUIDE_URL",
 	})
 
+	app.Command("check-config", "Resolve the collection of messages with a config file, and check it against expected routings", checkConfig)
+
 	app.Action = func() {
 		logger.InitDefaultLogger(*appName)
-		conf, err := config.ReadConfig(*configFile)
+		conf, err := config.NewLoader(*configFile)
 		if err != nil {
 			logger.Fatalf(nil, err, "Error reading the configuration")
 		}
 
+		reloadInterval, err := time.ParseDuration(*configReloadInterval)
+		if err != nil {
+			logger.Fatalf(nil, err, "Error reading the configuration reload interval")
+		}
+		go reloadConfig(conf, reloadInterval)
+
 		if *panicGuideUrl == "" {
 			logger.Fatalf(nil, errors.New("empty panicGuideUrl"), "Incorrect usage")
 		}
 
-		logger.Infof(nil, "[Startup] Using UUID paths configuration: %# v", *contentUUIDfields)
-		bodyParser := native.NewContentBodyParser(*contentUUIDfields)
-		writer := native.NewWriter(*nativeWriterAddress, *conf, bodyParser)
+		retryPolicy, err := newRetryPolicy(*nativeWriterMaxAttempts, *nativeWriterRetryBaseDelay, *nativeWriterRetryMaxDelay, *nativeWriterRetryJitter)
+		if err != nil {
+			logger.Fatalf(nil, err, "Error reading the native writer retry configuration")
+		}
+
+		httpClientConfig, err := newHTTPClientConfig(*nativeWriterTimeout, *nativeWriterDialTimeout, *nativeWriterTLSHandshakeTimeout, *nativeWriterResponseHeaderTimeout,
+			*nativeWriterMaxIdleConns, *nativeWriterMaxIdleConnsPerHost, *nativeWriterIdleConnTimeout, *nativeWriterKeepAlive)
+		if err != nil {
+			logger.Fatalf(nil, err, "Error reading the native writer HTTP client configuration")
+		}
+
+		if (*nativeWriterCertFile == "") != (*nativeWriterKeyFile == "") {
+			logger.Fatalf(nil, errors.New("the native writer certificate and key files must be given together"), "Error reading the native writer credentials configuration")
+		}
+		auth := native.AuthConfig{
+			CAFile:    *nativeWriterCAFile,
+			CertFile:  *nativeWriterCertFile,
+			KeyFile:   *nativeWriterKeyFile,
+			TokenFile: *nativeWriterTokenFile,
+		}
+
+		breaker, err := newCircuitBreaker(*nativeWriterBreakerFailureRate, *nativeWriterBreakerWindow, *nativeWriterBreakerOpenDuration, *nativeWriterBreakerProbes)
+		if err != nil {
+			logger.Fatalf(nil, err, "Error reading the native writer circuit breaker configuration")
+		}
+
+		logger.Infof(nil, "[Startup] Using UUID paths configuration: %# v, XML: %# v", *contentUUIDfields, *contentUUIDXMLFields)
+		bodyParser, err := native.NewContentBodyParser(*contentUUIDfields, *contentUUIDXMLFields)
+		if err != nil {
+			logger.Fatalf(nil, err, "Error reading the UUID paths configuration")
+		}
+		writerOptions := native.WriterOptions{
+			RetryPolicy:       retryPolicy,
+			HTTPClient:        httpClientConfig,
+			Auth:              auth,
+			ConditionalWrites: *nativeWriterConditionalWrites,
+			DryRun:            *dryRun,
+		}
+		var shadowWriter *native.ShadowWriter
+		primaryOptions := writerOptions
+		primaryOptions.Breaker = breaker
+		writer := native.NewWriter(*nativeWriterAddress, conf, bodyParser, primaryOptions)
+		if *nativeWriterShadowAddress != "" {
+			// the shadow writer has no circuit breaker, so that its failures never pause the consumption
+			logger.Infof(map[string]interface{}{"address": *nativeWriterShadowAddress}, "[Startup] Repeating writes to a shadow native writer")
+			shadowWriter = native.NewShadowWriter(writer, native.NewWriter(*nativeWriterShadowAddress, conf, bodyParser, writerOptions), *nativeWriterShadowQueueDepth)
+			writer = shadowWriter
+		}
 		logger.Infof(nil, "[Startup] Using native writer configuration: %# v", writer)
 
-		mh := queue.NewMessageHandler(writer, *contentType)
+		if *dryRun {
+			logger.Infof(nil, "[Startup] Running in dry-run mode, messages will not be written, forwarded or dead-lettered")
+		}
+		mh := queue.NewMessageHandler(writer, conf, *contentType)
 
-		var messageProducer kafka.Producer
+		var messageProducer, deadLetterProducer kafka.Producer
+		var producers *queue.ProducerPool
 		if *writeQueueAddress != "" {
-			messageProducer, err = kafka.NewPerseverantProducer(*writeQueueAddress, *writeQueueTopic, nil, 0, time.Minute)
-			if err != nil {
-				logger.Errorf(nil, err, "unable to create producer for %v/%v", *writeQueueAddress, *writeQueueTopic)
-			}
+			producers = queue.NewProducerPool(*writeQueueTopic, func(topic string) (kafka.Producer, error) {
+				p, err := kafka.NewPerseverantProducer(*writeQueueAddress, topic, nil, 0, time.Minute)
+				if err != nil {
+					logger.Errorf(nil, err, "unable to create producer for %v/%v", *writeQueueAddress, topic)
+				}
+				return p, err
+			})
+			messageProducer, _ = producers.Producer(*writeQueueTopic)
 			logger.Infof(nil, "[Startup] Producer: %# v", messageProducer)
-			mh.ForwardTo(messageProducer)
+			// producers of the forward topics of the routes are created upfront, so that they are health checked from the start
+			for _, topic := range conf.Current().ForwardTopics() {
+				producers.Producer(topic)
+			}
+			mh.ForwardByRoute(producers)
+		}
+
+		if *deadLetterTopic != "" {
+			if *writeQueueAddress == "" {
+				logger.Fatalf(nil, errors.New("empty write queue address"), "Dead-letter topic %v requires a write queue address", *deadLetterTopic)
+			}
+			deadLetterProducer, _ = producers.Producer(*deadLetterTopic)
+			logger.Infof(nil, "[Startup] Dead-letter producer: %# v", deadLetterProducer)
+			mh.DeadLetterTo(deadLetterProducer)
 		}
 
 		consumerConfig := kafka.DefaultConsumerConfig()
 		consumerConfig.Zookeeper.Logger = log.New(ioutil.Discard, "", 0)
-		messageConsumer, err := kafka.NewPerseverantConsumer(*readQueueAddresses, *readQueueGroup, []string{*readQueueTopic}, consumerConfig, time.Minute, nil)
-		if err != nil {
-			logger.Errorf(nil, err, "unable to create message consumer for %v/%v", *readQueueAddresses, *readQueueTopic)
+		var messageConsumer kafka.Consumer
+		if *workers > 1 {
+			messageConsumer = queue.NewConcurrentConsumer(*readQueueAddresses, *readQueueGroup, []string{*readQueueTopic}, consumerConfig, time.Minute, queue.ConcurrencyConfig{
+				Workers:     *workers,
+				QueueDepth:  *workerQueueDepth,
+				OrderingKey: queue.ContentUUIDOrderingKey(conf, bodyParser),
+			})
+		} else {
+			messageConsumer, err = kafka.NewPerseverantConsumer(*readQueueAddresses, *readQueueGroup, []string{*readQueueTopic}, consumerConfig, time.Minute, nil)
+			if err != nil {
+				logger.Errorf(nil, err, "unable to create message consumer for %v/%v", *readQueueAddresses, *readQueueTopic)
+			}
 		}
 
 		logger.Infof(nil, "[Startup] Consumer: %# v", messageConsumer)
@@ -143,8 +404,11 @@ func main() {
 			logger.Infof(map[string]interface{}{}, "[Startup] Producer: %# v", messageProducer)
 		}
 
-		go enableHealthCheck(*port, messageConsumer, messageProducer, writer, *panicGuideUrl)
+		go enableHealthCheck(*port, messageConsumer, messageProducer, producers, deadLetterProducer, writer, breaker, conf, mh, *panicGuideUrl)
 		startMessageConsumption(messageConsumer, mh.HandleMessage)
+		if shadowWriter != nil {
+			shadowWriter.Stop()
+		}
 	}
 
 	err := app.Run(os.Args)
@@ -153,14 +417,95 @@ func main() {
 	}
 }
 
-func enableHealthCheck(port string, consumer kafka.Consumer, producer kafka.Producer, nw native.Writer, pg string) {
-	hc := resources.NewHealthCheck(consumer, producer, nw, pg)
+func newRetryPolicy(maxAttempts int, baseDelay string, maxDelay string, jitter string) (native.RetryPolicy, error) {
+	policy := native.RetryPolicy{MaxAttempts: maxAttempts}
+	var err error
+	if policy.BaseDelay, err = time.ParseDuration(baseDelay); err != nil {
+		return policy, err
+	}
+	if policy.MaxDelay, err = time.ParseDuration(maxDelay); err != nil {
+		return policy, err
+	}
+	if policy.Jitter, err = strconv.ParseFloat(jitter, 64); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+func newHTTPClientConfig(timeout string, dialTimeout string, tlsHandshakeTimeout string, responseHeaderTimeout string,
+	maxIdleConns int, maxIdleConnsPerHost int, idleConnTimeout string, keepAlive string) (native.HTTPClientConfig, error) {
+	config := native.HTTPClientConfig{MaxIdleConns: maxIdleConns, MaxIdleConnsPerHost: maxIdleConnsPerHost}
+	durations := []struct {
+		value    string
+		duration *time.Duration
+	}{
+		{timeout, &config.Timeout},
+		{dialTimeout, &config.DialTimeout},
+		{tlsHandshakeTimeout, &config.TLSHandshakeTimeout},
+		{responseHeaderTimeout, &config.ResponseHeaderTimeout},
+		{idleConnTimeout, &config.IdleConnTimeout},
+		{keepAlive, &config.KeepAlive},
+	}
+	for _, d := range durations {
+		var err error
+		if *d.duration, err = time.ParseDuration(d.value); err != nil {
+			return config, err
+		}
+	}
+	config.DisableKeepAlives = config.KeepAlive <= 0
+	return config, nil
+}
+
+// newCircuitBreaker returns the circuit breaker around the native writer, nil if the failure rate disables it
+func newCircuitBreaker(failureRate string, window int, openDuration string, probes int) (*native.CircuitBreaker, error) {
+	policy := native.BreakerPolicy{Window: window, Probes: probes}
+	var err error
+	if policy.FailureRate, err = strconv.ParseFloat(failureRate, 64); err != nil {
+		return nil, err
+	}
+	if policy.FailureRate <= 0 {
+		return nil, nil
+	}
+	if policy.FailureRate > 1 {
+		return nil, fmt.Errorf("failure rate %v is greater than 1", policy.FailureRate)
+	}
+	if policy.OpenDuration, err = time.ParseDuration(openDuration); err != nil {
+		return nil, err
+	}
+	return native.NewCircuitBreaker(policy), nil
+}
+
+func reloadConfig(conf *config.Loader, interval time.Duration) {
+	if interval > 0 {
+		go conf.Watch(interval, nil)
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		conf.ReloadAndLog("SIGHUP")
+	}
+}
+
+func enableHealthCheck(port string, consumer kafka.Consumer, producer kafka.Producer, producers *queue.ProducerPool, deadLetterProducer kafka.Producer, nw native.Writer, breaker *native.CircuitBreaker, conf *config.Loader, ingester resources.Ingester, pg string) {
+	hc := resources.NewHealthCheck(consumer, producer, nw, conf, pg)
+	if producers != nil {
+		hc.CheckProducers(producers)
+	}
+	if deadLetterProducer != nil {
+		hc.CheckDeadLetterProducer(deadLetterProducer)
+	}
+	if breaker != nil {
+		hc.CheckBreaker(breaker)
+	}
 
 	r := mux.NewRouter()
 	r.HandleFunc("/__health", hc.Handler())
 	r.HandleFunc(httphandlers.GTGPath, httphandlers.NewGoodToGoHandler(hc.GTG)).Methods("GET")
 	r.HandleFunc(httphandlers.BuildInfoPath, httphandlers.BuildInfoHandler).Methods("GET")
 	r.HandleFunc(httphandlers.PingPath, httphandlers.PingHandler).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.Handle("/ingest", resources.NewIngestHandler(ingester)).Methods("POST")
 
 	http.Handle("/", r)
 	err := http.ListenAndServe(":"+port, nil)
@@ -172,7 +517,7 @@ func enableHealthCheck(port string, consumer kafka.Consumer, producer kafka.Prod
 func startMessageConsumption(messageConsumer kafka.Consumer, mh func(message kafka.FTMessage) error) {
 	messageConsumer.StartListening(mh)
 
-	ch := make(chan os.Signal)
+	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	<-ch
 	messageConsumer.Shutdown()
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "native_ingester"
+
+var (
+	// MessagesConsumed counts the consumed messages by origin system, content type and outcome of the ingestion
+	MessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_consumed_total",
+		Help:      "Number of consumed messages by origin system, content type of their route and outcome of the ingestion.",
+	}, []string{"origin_system", "content_type", "outcome"})
+
+	// NativeWriterRequestDuration observes the latency of the requests to the native writer by collection and HTTP method
+	NativeWriterRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "native_writer_request_duration_seconds",
+		Help:      "Latency of the requests to the native writer by collection and HTTP method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"collection", "method"})
+
+	// ForwardDuration observes the latency of forwarding messages to the write queue
+	ForwardDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "forward_duration_seconds",
+		Help:      "Latency of forwarding consumed messages to the write queue.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// SupersededWrites counts the conditional writes skipped because the native store holds a newer version of the content
+	SupersededWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "superseded_writes_total",
+		Help:      "Number of conditional writes skipped by collection, because the native store holds a newer version of the content.",
+	}, []string{"collection"})
+
+	// DryRunWrites counts the calls to the native writer skipped in dry-run mode by collection and HTTP method
+	DryRunWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dry_run_writes_total",
+		Help:      "Number of calls to the native writer skipped in dry-run mode by collection and HTTP method.",
+	}, []string{"collection", "method"})
+
+	// NativeWriterCircuitState is the state of the circuit breaker around the native writer
+	NativeWriterCircuitState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "native_writer_circuit_state",
+		Help:      "State of the circuit breaker around the native writer: 0 closed, 1 half-open, 2 open.",
+	})
+
+	// ShadowWrites counts the writes to the shadow native writer by collection and result of their comparison with the primary writes
+	ShadowWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shadow_writes_total",
+		Help:      "Number of writes to the shadow native writer by collection and result: match, status-mismatch, content-mismatch or dropped.",
+	}, []string{"collection", "result"})
+
+	// MessagesInFlight is the number of messages currently being ingested
+	MessagesInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "messages_in_flight",
+		Help:      "Number of messages currently being ingested.",
+	})
+)
@@ -50,12 +50,21 @@ func (w *WriterMock) GetCollection(originID string, contentType string) (string,
 	return args.String(0), args.Error(1)
 }
 
-func (w *WriterMock) WriteToCollection(msg native.NativeMessage, collection string) (string, string, error) {
+func (w *WriterMock) WriteToCollection(msg native.NativeMessage, collection string) (native.WriteResult, error) {
 	args := w.Called(msg, collection)
-	return args.String(0), args.String(1), args.Error(2)
+	return args.Get(0).(native.WriteResult), args.Error(1)
 }
 
 func (w *WriterMock) ConnectivityCheck() (string, error) {
 	args := w.Called()
 	return args.String(0), args.Error(1)
 }
+
+type ConfigCheckerMock struct {
+	mock.Mock
+}
+
+func (c *ConfigCheckerMock) Check() (string, error) {
+	args := c.Called()
+	return args.String(0), args.Error(1)
+}
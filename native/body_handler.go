@@ -0,0 +1,99 @@
+package native
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/Financial-Times/native-ingester/xpath"
+)
+
+// bodyHandler decodes the body of native content of a kind of media type into the message,
+// attaching the lastModified and publishReference of the content to its body or, when the body cannot be modified, to its headers
+type bodyHandler interface {
+	decode(msg *NativeMessage, contentBody string, timestamp string, transactionID string) error
+}
+
+// bodyHandlerFor returns the handler of the body of native content of the media type.
+// Any media type that is neither XML nor binary is handled as JSON, as it was before other formats were supported.
+func bodyHandlerFor(contentType string) bodyHandler {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch {
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xmlBodyHandler{}
+	case mediaType == "application/octet-stream" || mediaType == "application/pdf" || mediaType == "application/zip" ||
+		strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/"):
+		return binaryBodyHandler{}
+	default:
+		return jsonBodyHandler{}
+	}
+}
+
+// jsonBodyHandler decodes a JSON object, in which it sets the lastModified and publishReference fields
+type jsonBodyHandler struct{}
+
+func (jsonBodyHandler) decode(msg *NativeMessage, contentBody string, timestamp string, transactionID string) error {
+	// numbers are kept as json.Number so that large integers are not rounded through float64
+	body := make(map[string]interface{})
+	decoder := json.NewDecoder(strings.NewReader(contentBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON object")
+	}
+
+	rawBody, err := injectFields([]byte(contentBody), []rawField{
+		{"lastModified", timestamp},
+		{"publishReference", transactionID},
+	})
+	if err != nil {
+		return err
+	}
+
+	body["lastModified"] = timestamp
+	body["publishReference"] = transactionID
+	msg.body = body
+	msg.rawBody = rawBody
+	return nil
+}
+
+// xmlBodyHandler parses an XML document, which is written unchanged with lastModified and publishReference in headers
+type xmlBodyHandler struct{}
+
+func (xmlBodyHandler) decode(msg *NativeMessage, contentBody string, timestamp string, transactionID string) error {
+	doc, err := xpath.Parse(strings.NewReader(contentBody))
+	if err != nil {
+		return err
+	}
+	msg.xmlBody = doc
+	msg.rawBody = []byte(contentBody)
+	setPublishHeaders(msg, timestamp, transactionID)
+	return nil
+}
+
+// binaryBodyHandler decodes opaque content wrapped in base64, whose UUID is taken from the Content-UUID header.
+// The content is written unwrapped, with lastModified and publishReference in headers.
+type binaryBodyHandler struct{}
+
+func (binaryBodyHandler) decode(msg *NativeMessage, contentBody string, timestamp string, transactionID string) error {
+	rawBody, err := base64.StdEncoding.DecodeString(strings.TrimSpace(contentBody))
+	if err != nil {
+		return err
+	}
+	msg.rawBody = rawBody
+	setPublishHeaders(msg, timestamp, transactionID)
+	return nil
+}
+
+func setPublishHeaders(msg *NativeMessage, timestamp string, transactionID string) {
+	msg.headers[lastModifiedHeader] = timestamp
+	msg.headers[publishReferenceHeader] = transactionID
+}
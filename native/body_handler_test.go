@@ -0,0 +1,143 @@
+package native
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const anXMLBody = `<?xml version="1.0"?>
+<article uuid="572D0ACC-3F12-4E70-8830-8092C1042A52"><title>Title</title></article>`
+
+func TestBodyHandlerFor(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bodyHandler
+	}{
+		{"", jsonBodyHandler{}},
+		{"application/json", jsonBodyHandler{}},
+		{"application/json; version=1.0", jsonBodyHandler{}},
+		{"application/vnd.ft-upp-audio", jsonBodyHandler{}},
+		{"application/xml", xmlBodyHandler{}},
+		{"Text/XML; charset=utf-8", xmlBodyHandler{}},
+		{"application/vnd.ft-nitf+xml", xmlBodyHandler{}},
+		{"application/octet-stream", binaryBodyHandler{}},
+		{"image/png", binaryBodyHandler{}},
+		{"application/pdf", binaryBodyHandler{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, bodyHandlerFor(tt.contentType), "Unexpected handler for %q", tt.contentType)
+	}
+}
+
+func TestBuildNativeMessageFailsWithInvalidBodyOfItsType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", "<article/>"},
+		{"application/xml", `{"uuid": "572d0acc-3f12-4e70-8830-8092c1042a52"}`},
+		{"application/xml", "<article>"},
+		{"application/octet-stream", "not base64!"},
+	}
+	for _, tt := range tests {
+		_, err := NewNativeMessage(tt.contentType, tt.body, aTimestamp, publishRef, messageTypeContentPublished)
+		assert.True(t, errors.Is(err, ErrInvalidBody), "It should fail to decode %q as %v", tt.body, tt.contentType)
+	}
+}
+
+func TestWriteXMLContent(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, []string{"/article/@uuid"})
+	require.NoError(t, err)
+	conf, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "/"+methodeCollectionName+"/"+aUUID, req.URL.Path)
+		assert.Equal(t, anXMLBody, string(body), "The XML body should be written unchanged")
+		assert.Equal(t, "application/xml", req.Header.Get(contentTypeHeader))
+		assert.Equal(t, aTimestamp, req.Header.Get(lastModifiedHeader))
+		assert.Equal(t, publishRef, req.Header.Get(publishReferenceHeader))
+	}))
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("application/xml", anXMLBody, aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+
+	w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
+	assert.NoError(t, err)
+	assert.Equal(t, aUUID, result.UUID)
+}
+
+func TestWriteXMLContentWithUUIDXMLFieldsOfRoute(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, []string{"/article/@id"})
+	require.NoError(t, err)
+	conf, err := getConfig(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "collection": "methode", "uuid_xml_fields": ["//@uuid"]}
+		]
+	}`)
+	require.NoError(t, err)
+
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("application/xml", anXMLBody, aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+	msg.AddOriginSystemIDHeader(methodeOriginSystemID)
+
+	w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
+	assert.NoError(t, err)
+	assert.Equal(t, aUUID, result.UUID)
+}
+
+func TestWriteBinaryContent(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	conf, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+	content := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "PUT", req.Method)
+		assert.Equal(t, "/"+methodeCollectionName+"/"+aUUID, req.URL.Path)
+		assert.Equal(t, content, body, "The binary content should be written unwrapped")
+		assert.Equal(t, "image/png", req.Header.Get(contentTypeHeader))
+		assert.Equal(t, aTimestamp, req.Header.Get(lastModifiedHeader))
+		assert.Equal(t, publishRef, req.Header.Get(publishReferenceHeader))
+	}))
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("image/png", base64.StdEncoding.EncodeToString(content), aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+	msg.AddContentUUIDHeader(aUUID)
+
+	w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
+	assert.NoError(t, err)
+	assert.Equal(t, aUUID, result.UUID)
+}
+
+func TestWriteBinaryContentWithoutUUIDHeader(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	conf, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+
+	msg, err := NewNativeMessage("application/octet-stream", "AAEC", aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+
+	w := NewWriter("http://localhost:0", conf, parser, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+	assert.True(t, errors.Is(err, ErrUUIDNotFound), "It should not find the UUID of binary content without a Content-UUID header")
+}
@@ -0,0 +1,214 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/native-ingester/metrics"
+)
+
+// BreakerPolicy defines when the circuit breaker around the native writer opens and how it recovers
+type BreakerPolicy struct {
+	// FailureRate is the fraction, between 0 and 1, of failed writes among the last Window writes that opens the breaker.
+	// Only writes that failed because the native writer is unavailable, after their retries, count as failures.
+	FailureRate float64
+	// Window is the number of latest writes the failure rate is computed over, the breaker does not open before as many writes are made
+	Window int
+	// OpenDuration is how long the breaker stays open before half-opening to let a probe write through
+	OpenDuration time.Duration
+	// Probes is the number of successful probe writes in a row that close a half-open breaker
+	Probes int
+}
+
+// BreakerState is the state of a circuit breaker, whose value is reported by the native_writer_circuit_state metric
+type BreakerState int
+
+// States of a circuit breaker
+const (
+	// BreakerClosed lets all the writes through
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets one probe write through at a time, the others wait for the breaker to close
+	BreakerHalfOpen
+	// BreakerOpen lets no write through until its open duration is over
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// CircuitBreaker stops calling the native writer once too many calls fail.
+// While it is open, writes wait instead of failing, so that messages, which are handled one at a time by each worker,
+// pause the consumption rather than being consumed and dropped.
+type CircuitBreaker struct {
+	policy BreakerPolicy
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	state    BreakerState
+	outcomes []bool
+	next     int
+	count    int
+	failures int
+	openedAt time.Time
+	probing  bool
+	probed   int
+	// changed is closed and replaced whenever a waiting write may be let through
+	changed chan struct{}
+}
+
+// NewCircuitBreaker returns a new instance of a closed CircuitBreaker
+func NewCircuitBreaker(policy BreakerPolicy) *CircuitBreaker {
+	if policy.Window < 1 {
+		policy.Window = 1
+	}
+	if policy.Probes < 1 {
+		policy.Probes = 1
+	}
+	metrics.NativeWriterCircuitState.Set(float64(BreakerClosed))
+	return &CircuitBreaker{
+		policy:   policy,
+		now:      time.Now,
+		outcomes: make([]bool, policy.Window),
+		changed:  make(chan struct{}),
+	}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// Check reports an error while the breaker is not closed
+func (b *CircuitBreaker) Check() (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return "Consumption is paused", fmt.Errorf("circuit breaker is open until %v, too many writes to the native writer failed", b.openedAt.Add(b.policy.OpenDuration).Format(time.RFC3339))
+	case BreakerHalfOpen:
+		return "Consumption is paused", errors.New("circuit breaker is half-open, probing the native writer")
+	}
+	return "Circuit breaker is closed", nil
+}
+
+// acquire waits until a write can be made, and returns true if the write is a probe of a half-open breaker
+func (b *CircuitBreaker) acquire() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for {
+		var wait <-chan time.Time
+		switch b.state {
+		case BreakerClosed:
+			return false
+		case BreakerOpen:
+			remaining := b.openedAt.Add(b.policy.OpenDuration).Sub(b.now())
+			if remaining <= 0 {
+				b.setState(BreakerHalfOpen)
+				continue
+			}
+			wait = time.After(remaining)
+		case BreakerHalfOpen:
+			if !b.probing {
+				b.probing = true
+				return true
+			}
+		}
+
+		changed := b.changed
+		b.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-wait:
+		}
+		b.mutex.Lock()
+	}
+}
+
+// record counts the outcome of a write let through by acquire
+func (b *CircuitBreaker) record(probe bool, err error) {
+	failed := errors.Is(err, ErrWriterUnavailable)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if probe {
+		b.probing = false
+		switch {
+		case failed:
+			b.open()
+		case b.probed+1 >= b.policy.Probes:
+			b.setState(BreakerClosed)
+		default:
+			b.probed++
+			b.notify()
+		}
+		return
+	}
+	// writes let through before the breaker opened do not count any more
+	if b.state != BreakerClosed {
+		return
+	}
+
+	if b.count == len(b.outcomes) {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+
+	if b.count == len(b.outcomes) && float64(b.failures) >= b.policy.FailureRate*float64(b.count) && b.failures > 0 {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.now()
+	b.setState(BreakerOpen)
+}
+
+func (b *CircuitBreaker) setState(state BreakerState) {
+	if state == BreakerClosed {
+		b.next, b.count, b.failures = 0, 0, 0
+	}
+	b.probed = 0
+	if state != b.state {
+		fields := map[string]interface{}{"breakerState": state.String()}
+		switch state {
+		case BreakerOpen:
+			fields["openDuration"] = b.policy.OpenDuration.String()
+			logger.Warnf(fields, "Circuit breaker around the native writer opened, pausing consumption")
+		case BreakerHalfOpen:
+			logger.Infof(fields, "Circuit breaker around the native writer half-opened, probing the native writer")
+		case BreakerClosed:
+			logger.Infof(fields, "Circuit breaker around the native writer closed, resuming consumption")
+		}
+	}
+	b.state = state
+	metrics.NativeWriterCircuitState.Set(float64(state))
+	b.notify()
+}
+
+// notify wakes up the writes waiting for the breaker
+func (b *CircuitBreaker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
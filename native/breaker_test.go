@@ -0,0 +1,142 @@
+package native
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnavailable = classify(ErrWriterUnavailable, errors.New("connection refused"))
+
+func newTestBreaker(policy BreakerPolicy) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(policy)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreakerOpensAtFailureRate(t *testing.T) {
+	b, _ := newTestBreaker(BreakerPolicy{FailureRate: 0.5, Window: 4, OpenDuration: time.Minute})
+
+	b.record(b.acquire(), errUnavailable)
+	b.record(b.acquire(), errUnavailable)
+	b.record(b.acquire(), nil)
+	assert.Equal(t, BreakerClosed, b.State(), "It should not open before the window is full")
+
+	b.record(b.acquire(), nil)
+	assert.Equal(t, BreakerOpen, b.State(), "It should open when half of the writes in the window failed")
+}
+
+func TestBreakerIgnoresFailuresOtherThanUnavailability(t *testing.T) {
+	b, _ := newTestBreaker(BreakerPolicy{FailureRate: 1, Window: 3, OpenDuration: time.Minute})
+
+	b.record(b.acquire(), &WriterRejectedError{StatusCode: http.StatusBadRequest})
+	b.record(b.acquire(), classify(ErrSuperseded, errors.New("412")))
+	b.record(b.acquire(), errUnavailable)
+	assert.Equal(t, BreakerClosed, b.State(), "Rejected and superseded writes should count as successes")
+}
+
+func TestBreakerSlidesItsWindow(t *testing.T) {
+	b, _ := newTestBreaker(BreakerPolicy{FailureRate: 1, Window: 3, OpenDuration: time.Minute})
+
+	b.record(b.acquire(), nil)
+	b.record(b.acquire(), errUnavailable)
+	b.record(b.acquire(), errUnavailable)
+	assert.Equal(t, BreakerClosed, b.State())
+
+	b.record(b.acquire(), errUnavailable)
+	assert.Equal(t, BreakerOpen, b.State(), "It should open once the successful write leaves the window")
+}
+
+func TestBreakerHalfOpensAndClosesAfterProbes(t *testing.T) {
+	b, now := newTestBreaker(BreakerPolicy{FailureRate: 1, Window: 1, OpenDuration: time.Minute, Probes: 2})
+	b.record(b.acquire(), errUnavailable)
+	require.Equal(t, BreakerOpen, b.State())
+	_, err := b.Check()
+	assert.Error(t, err, "The check should fail while the breaker is open")
+
+	*now = now.Add(time.Minute)
+	assert.True(t, b.acquire(), "The first write after the open duration should be a probe")
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	b.record(true, nil)
+	assert.Equal(t, BreakerHalfOpen, b.State(), "It should stay half-open until all probes succeed")
+
+	assert.True(t, b.acquire())
+	b.record(true, nil)
+	assert.Equal(t, BreakerClosed, b.State())
+	_, err = b.Check()
+	assert.NoError(t, err, "The check should pass once the breaker is closed")
+
+	b.record(b.acquire(), errUnavailable)
+	assert.Equal(t, BreakerOpen, b.State(), "The failure rate should be computed over the writes after closing")
+}
+
+func TestBreakerReopensWhenProbeFails(t *testing.T) {
+	b, now := newTestBreaker(BreakerPolicy{FailureRate: 1, Window: 1, OpenDuration: time.Minute})
+	b.record(b.acquire(), errUnavailable)
+
+	*now = now.Add(time.Minute)
+	require.True(t, b.acquire())
+	b.record(true, errUnavailable)
+	assert.Equal(t, BreakerOpen, b.State())
+}
+
+func TestBreakerPausesWritesWhileOpen(t *testing.T) {
+	b := NewCircuitBreaker(BreakerPolicy{FailureRate: 1, Window: 1, OpenDuration: 50 * time.Millisecond})
+	b.record(b.acquire(), errUnavailable)
+
+	start := time.Now()
+	probe := b.acquire()
+	assert.True(t, probe, "The write should be let through as a probe")
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "The write should wait for the open duration")
+
+	released := make(chan bool)
+	go func() {
+		released <- b.acquire()
+	}()
+	select {
+	case <-released:
+		t.Fatal("A second write should wait for the probe to complete")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.record(probe, nil)
+	select {
+	case probe := <-released:
+		assert.False(t, probe, "The write should be let through by the closed breaker")
+	case <-time.After(time.Second):
+		t.Fatal("The write should be let through once the probe succeeds")
+	}
+}
+
+func TestWriteWithBreakerPausesWhileNativeWriterFails(t *testing.T) {
+	nws, calls := setupFlakyNativeWriterService(t, []int{503, 503, 200}, nil)
+	defer nws.Close()
+
+	breaker := NewCircuitBreaker(BreakerPolicy{FailureRate: 1, Window: 2, OpenDuration: 20 * time.Millisecond})
+	p := new(ContentBodyParserMock)
+	p.On("getUUID", aContentBody).Return(aUUID, nil)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{Breaker: breaker})
+
+	write := func() error {
+		msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
+		require.NoError(t, err)
+		msg.AddContentTypeHeader(aContentType)
+		_, err = w.WriteToCollection(msg, methodeCollectionName)
+		return err
+	}
+
+	assert.True(t, errors.Is(write(), ErrWriterUnavailable))
+	assert.True(t, errors.Is(write(), ErrWriterUnavailable))
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	assert.NoError(t, write(), "The write should wait for the breaker to half-open and succeed as a probe")
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.Equal(t, int32(3), *calls)
+}
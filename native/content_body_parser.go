@@ -2,34 +2,123 @@ package native
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
-	"github.com/jmoiron/jsonq"
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/jsonpath"
+	"github.com/Financial-Times/native-ingester/xpath"
 	uuidParser "github.com/satori/go.uuid"
 )
 
 // ContentBodyParser parses the body of native content
 type ContentBodyParser interface {
 	getUUID(body map[string]interface{}) (string, error)
+	getXMLUUID(doc *xpath.Node) (string, error)
 }
 
 type contentBodyParser struct {
-	uuidJSONPaths []string
+	uuidJSONPaths []*jsonpath.Path
+	uuidXPaths    []*xpath.Path
 }
 
-// NewContentBodyParser returns a new instace of a ContentBodyParser
-func NewContentBodyParser(uuidJSONPaths []string) ContentBodyParser {
-	return &contentBodyParser{uuidJSONPaths}
+// NewContentBodyParser returns a new instace of a ContentBodyParser.
+// The UUID of JSON content is the first valid UUID matched by the JSONPaths, and the UUID of XML content
+// the first valid UUID matched by the XPaths, tried in order.
+// It returns an error if any of the paths is not valid.
+func NewContentBodyParser(uuidJSONPaths []string, uuidXPaths []string) (ContentBodyParser, error) {
+	jsonPaths := make([]*jsonpath.Path, 0, len(uuidJSONPaths))
+	for _, expr := range uuidJSONPaths {
+		path, err := jsonpath.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		jsonPaths = append(jsonPaths, path)
+	}
+	xPaths := make([]*xpath.Path, 0, len(uuidXPaths))
+	for _, expr := range uuidXPaths {
+		path, err := xpath.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		xPaths = append(xPaths, path)
+	}
+	return &contentBodyParser{jsonPaths, xPaths}, nil
 }
 
+// getUUID returns the first valid UUID in lower-case canonical form, whatever form it has in the body.
+// The nil UUID is rejected, so that a valid UUID matched by a later path is used instead.
 func (p contentBodyParser) getUUID(body map[string]interface{}) (string, error) {
-	jq := jsonq.NewQuery(body)
+	var values []string
 	for _, uuidPath := range p.uuidJSONPaths {
-		uuid, jsonPathErr := jq.String(strings.Split(uuidPath, ".")...)
-		_, uuidParsingError := uuidParser.FromString(uuid)
-		if jsonPathErr == nil && uuidParsingError == nil {
+		for _, value := range uuidPath.Find(body) {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return firstUUID(values)
+}
+
+// getXMLUUID returns the first valid UUID matched by the XPaths, like getUUID does for JSON content
+func (p contentBodyParser) getXMLUUID(doc *xpath.Node) (string, error) {
+	var values []string
+	for _, uuidPath := range p.uuidXPaths {
+		for _, value := range uuidPath.Find(doc) {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+	return firstUUID(values)
+}
+
+// firstUUID returns the first valid UUID of the values in lower-case canonical form,
+// or ErrInvalidUUID if the only UUIDs were rejected
+func firstUUID(values []string) (string, error) {
+	var rejected error
+	for _, value := range values {
+		uuid, err := normaliseUUID(value)
+		if err == nil {
 			return uuid, nil
 		}
+		if rejected == nil && errors.Is(err, ErrInvalidUUID) {
+			rejected = err
+		}
+	}
+	if rejected != nil {
+		return "", rejected
+	}
+	return "", ErrUUIDNotFound
+}
+
+// routeBodyParser uses the UUID fields declared by a route instead of the global ones, for each format it declares them
+type routeBodyParser struct {
+	ContentBodyParser
+	route *config.OriginSystemConfig
+}
+
+func (p routeBodyParser) getUUID(body map[string]interface{}) (string, error) {
+	if len(p.route.UUIDPaths()) > 0 {
+		return contentBodyParser{uuidJSONPaths: p.route.UUIDPaths()}.getUUID(body)
+	}
+	return p.ContentBodyParser.getUUID(body)
+}
+
+func (p routeBodyParser) getXMLUUID(doc *xpath.Node) (string, error) {
+	if len(p.route.UUIDXPaths()) > 0 {
+		return contentBodyParser{uuidXPaths: p.route.UUIDXPaths()}.getXMLUUID(doc)
+	}
+	return p.ContentBodyParser.getXMLUUID(doc)
+}
+
+// normaliseUUID returns the UUID in lower-case canonical form, failing with ErrUUIDNotFound if the value is not a UUID
+// and with ErrInvalidUUID if it is the nil UUID
+func normaliseUUID(value string) (string, error) {
+	uuid, err := uuidParser.FromString(value)
+	if err != nil {
+		return "", ErrUUIDNotFound
+	}
+	if uuidParser.Equal(uuid, uuidParser.Nil) {
+		return "", classify(ErrInvalidUUID, fmt.Errorf("nil UUID %q is not accepted", value))
 	}
-	return "", errors.New("UUID not found")
+	return uuid.String(), nil
 }
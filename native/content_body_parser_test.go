@@ -2,9 +2,13 @@ package native
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/Financial-Times/native-ingester/xpath"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var happyTests = []struct {
@@ -37,6 +41,54 @@ var happyTests = []struct {
 		[]string{"uuid", "post.uuid", "data.uuidv3"},
 		"07ac9fad-6434-47c7-b7c4-34361a048d07",
 	},
+	{
+		`{"items": [{"id": "07ac9fad-6434-47c7-b7c4-34361a048d07"}]}`,
+		[]string{"uuid", "items[0].id"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"items": [{"id": "07ac9fad-6434-47c7-b7c4-34361a048d07"}]}`,
+		[]string{"items.0.id"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"content.uuid": "07ac9fad-6434-47c7-b7c4-34361a048d07"}`,
+		[]string{"$['content.uuid']"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"identifiers": [` +
+			`{"authority": "http://api.ft.com/system/FTCOM-METHODE", "value": "not-a-uuid"},` +
+			`{"authority": "http://api.ft.com/system/WORDPRESS", "value": "07ac9fad-6434-47c7-b7c4-34361a048d07"}` +
+			`]}`,
+		[]string{"identifiers[?(@.authority=='http://api.ft.com/system/WORDPRESS')].value"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"identifiers": [{"value": "not-a-uuid"}, {"value": 42}, {"value": "07ac9fad-6434-47c7-b7c4-34361a048d07"}]}`,
+		[]string{"$.identifiers[*].value"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"uuid": "07AC9FAD-6434-47C7-B7C4-34361A048D07"}`,
+		[]string{"uuid"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"uuid": "{07ac9fad-6434-47c7-b7c4-34361a048d07}"}`,
+		[]string{"uuid"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"uuid": "urn:uuid:07ac9fad-6434-47c7-b7c4-34361a048d07"}`,
+		[]string{"uuid"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
+	{
+		`{"uuid": "00000000-0000-0000-0000-000000000000", "id": "07ac9fad-6434-47c7-b7c4-34361a048d07"}`,
+		[]string{"uuid", "id"},
+		"07ac9fad-6434-47c7-b7c4-34361a048d07",
+	},
 }
 
 var unhappyTests = []struct {
@@ -67,11 +119,16 @@ var unhappyTests = []struct {
 			"}",
 		[]string{"uuid", "post.uuid"},
 	},
+	{
+		`{"items": [{"id": "07ac9fad-6434-47c7-b7c4-34361a048d07"}]}`,
+		[]string{"items[1].id", "$..uuid"},
+	},
 }
 
 func TestExtractUUIDSuccessfully(t *testing.T) {
 	for _, test := range happyTests {
-		bodyParser := NewContentBodyParser(test.paths)
+		bodyParser, err := NewContentBodyParser(test.paths, nil)
+		assert.NoError(t, err, "The paths should be valid")
 		body := make(map[string]interface{})
 		json.Unmarshal([]byte(test.msgBody), &body)
 		actualUUID, err := bodyParser.getUUID(body)
@@ -82,10 +139,48 @@ func TestExtractUUIDSuccessfully(t *testing.T) {
 
 func TestExtractUUIDFailure(t *testing.T) {
 	for _, test := range unhappyTests {
-		bodyParser := NewContentBodyParser(test.paths)
+		bodyParser, err := NewContentBodyParser(test.paths, nil)
+		assert.NoError(t, err, "The paths should be valid")
 		body := make(map[string]interface{})
 		json.Unmarshal([]byte(test.msgBody), &body)
-		_, err := bodyParser.getUUID(body)
+		_, err = bodyParser.getUUID(body)
 		assert.Error(t, err, "The parsing should return an error")
 	}
 }
+
+func TestNewContentBodyParserFailsWithInvalidPath(t *testing.T) {
+	_, err := NewContentBodyParser([]string{"uuid", "items[0"}, nil)
+	assert.Error(t, err, "It should return an error for an invalid JSONPath")
+}
+
+func TestExtractNilUUIDIsRejected(t *testing.T) {
+	bodyParser, err := NewContentBodyParser([]string{"uuid", "id"}, nil)
+	assert.NoError(t, err, "The paths should be valid")
+	body := map[string]interface{}{"uuid": "00000000-0000-0000-0000-000000000000", "id": "not-a-uuid"}
+
+	_, err = bodyParser.getUUID(body)
+	assert.True(t, errors.Is(err, ErrInvalidUUID), "It should return an invalid UUID error")
+}
+
+func TestExtractXMLUUID(t *testing.T) {
+	doc, err := xpath.Parse(strings.NewReader(`<article id="not-a-uuid">
+		<uuid> 07AC9FAD-6434-47C7-B7C4-34361A048D07 </uuid>
+	</article>`))
+	require.NoError(t, err)
+
+	bodyParser, err := NewContentBodyParser(nil, []string{"/article/@id", "//uuid"})
+	assert.NoError(t, err, "The paths should be valid")
+	actualUUID, err := bodyParser.getXMLUUID(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "07ac9fad-6434-47c7-b7c4-34361a048d07", actualUUID, "The first valid UUID should be normalised")
+
+	bodyParser, err = NewContentBodyParser([]string{"uuid"}, []string{"/article/@uuid"})
+	assert.NoError(t, err, "The paths should be valid")
+	_, err = bodyParser.getXMLUUID(doc)
+	assert.True(t, errors.Is(err, ErrUUIDNotFound), "It should not find a UUID")
+}
+
+func TestNewContentBodyParserFailsWithInvalidXPath(t *testing.T) {
+	_, err := NewContentBodyParser([]string{"uuid"}, []string{"/article[1"})
+	assert.Error(t, err, "It should return an error for an invalid XPath")
+}
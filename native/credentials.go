@@ -0,0 +1,211 @@
+package native
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuthConfig defines the credentials of the calls to the native writer. All the files are optional.
+type AuthConfig struct {
+	// CAFile is the PEM bundle of the CAs verifying the certificate of the native writer, instead of the system ones
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented to the native writer, for mutual TLS
+	CertFile string
+	KeyFile  string
+	// TokenFile holds the bearer token sent in the Authorization header of each request
+	TokenFile string
+}
+
+func (c AuthConfig) enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.TokenFile != ""
+}
+
+func (c AuthConfig) usesTLS() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != ""
+}
+
+// credentials loads the credentials of the native writer from their files, and loads them again when the files change,
+// so that rotated certificates and tokens are used without a restart
+type credentials struct {
+	config AuthConfig
+	caErr  error
+	cert   *watchedFile
+	key    *watchedFile
+	token  *watchedFile
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+}
+
+func newCredentials(config AuthConfig) *credentials {
+	c := &credentials{config: config}
+	if config.CertFile != "" || config.KeyFile != "" {
+		c.cert = &watchedFile{path: config.CertFile}
+		c.key = &watchedFile{path: config.KeyFile}
+	}
+	if config.TokenFile != "" {
+		c.token = &watchedFile{path: config.TokenFile}
+	}
+	return c
+}
+
+// tlsConfig returns the TLS configuration of the transport, nil if the default one is used
+func (c *credentials) tlsConfig() *tls.Config {
+	if !c.config.usesTLS() {
+		return nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.config.CAFile != "" {
+		pool, err := loadCAs(c.config.CAFile)
+		if err != nil {
+			// an empty pool fails the handshakes, which is safer than trusting the system CAs
+			c.caErr = err
+			pool = x509.NewCertPool()
+		}
+		config.RootCAs = pool
+	}
+	if c.cert != nil {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.clientCertificate()
+		}
+	}
+	return config
+}
+
+func loadCAs(path string) (*x509.CertPool, error) {
+	bundle, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading the CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("loading the CA bundle: no PEM certificate found in %v", path)
+	}
+	return pool, nil
+}
+
+func (c *credentials) clientCertificate() (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	certPEM, certChanged, err := c.cert.read()
+	if err != nil {
+		return nil, fmt.Errorf("loading the client certificate: %w", err)
+	}
+	keyPEM, keyChanged, err := c.key.read()
+	if err != nil {
+		return nil, fmt.Errorf("loading the client key: %w", err)
+	}
+	if c.certificate == nil || certChanged || keyChanged {
+		certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			c.certificate = nil
+			return nil, fmt.Errorf("loading the client certificate: %w", err)
+		}
+		c.certificate = &certificate
+	}
+	return c.certificate, nil
+}
+
+func (c *credentials) bearerToken() (string, error) {
+	token, _, err := c.token.read()
+	if err != nil {
+		return "", fmt.Errorf("loading the bearer token: %w", err)
+	}
+	token = bytes.TrimSpace(token)
+	if len(token) == 0 {
+		return "", fmt.Errorf("loading the bearer token: %v is empty", c.config.TokenFile)
+	}
+	return string(token), nil
+}
+
+// check loads all the credentials, returning the first failure
+func (c *credentials) check() error {
+	if c.caErr != nil {
+		return c.caErr
+	}
+	if c.cert != nil {
+		if _, err := c.clientCertificate(); err != nil {
+			return err
+		}
+	}
+	if c.token != nil {
+		if _, err := c.bearerToken(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authTransport adds the credentials to each request to the native writer
+type authTransport struct {
+	base        http.RoundTripper
+	credentials *credentials
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.credentials.caErr != nil {
+		closeRequestBody(req)
+		return nil, t.credentials.caErr
+	}
+	if t.credentials.token == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	token, err := t.credentials.bearerToken()
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	// a RoundTripper must not modify the request
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(authReq)
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// watchedFile caches the content of a file, which is read again when its modification time or size changes
+type watchedFile struct {
+	path string
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	content []byte
+}
+
+// read returns the content of the file, and whether it changed since the last read
+func (f *watchedFile) read() ([]byte, bool, error) {
+	if f.path == "" {
+		return nil, false, errors.New("no file given")
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.content != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.content, false, nil
+	}
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+	f.content, f.modTime, f.size = content, info.ModTime(), info.Size()
+	return content, true, nil
+}
@@ -0,0 +1,163 @@
+package native
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, content, 0600))
+	return path
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key, returning the certificate to trust it
+func writeClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "native-ingester"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := writeTestFile(t, dir, "client.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyFile := writeTestFile(t, dir, "client.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return cert, certFile, keyFile
+}
+
+func writeServerCA(t *testing.T, dir string, server *httptest.Server) string {
+	return writeTestFile(t, dir, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestWriteOverMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clientCert, certFile, keyFile := writeClientCertificate(t, dir)
+	nws := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Len(t, req.TLS.PeerCertificates, 1, "The client should present its certificate")
+		assert.Equal(t, "native-ingester", req.TLS.PeerCertificates[0].Subject.CommonName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	nws.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	nws.StartTLS()
+	defer nws.Close()
+
+	auth := AuthConfig{CAFile: writeServerCA(t, dir, nws), CertFile: certFile, KeyFile: keyFile}
+	assert.NoError(t, writeWithOptions(t, nws.URL, WriterOptions{Auth: auth}))
+
+	_, err = NewWriter(nws.URL, nil, nil, WriterOptions{Auth: auth}).ConnectivityCheck()
+	assert.NoError(t, err)
+}
+
+func TestWriteOverTLSFailsWithUnknownCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	nws := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nws.Close()
+	_, otherCAFile, _ := writeClientCertificate(t, dir)
+
+	err = writeWithOptions(t, nws.URL, WriterOptions{Auth: AuthConfig{CAFile: otherCAFile}})
+	assert.Error(t, err, "The certificate of the native writer should not be trusted")
+}
+
+func TestWriteWithBearerToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var authorization string
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = req.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nws.Close()
+
+	tokenFile := writeTestFile(t, dir, "token", []byte("first-token\n"))
+	w := NewWriter(nws.URL, nil, nil, WriterOptions{Auth: AuthConfig{TokenFile: tokenFile}})
+
+	_, err = w.ConnectivityCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer first-token", authorization)
+
+	writeTestFile(t, dir, "token", []byte("rotated-token-with-another-length\n"))
+	_, err = w.ConnectivityCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer rotated-token-with-another-length", authorization, "The rotated token should be read again")
+
+	assert.NoError(t, writeWithOptions(t, nws.URL, WriterOptions{Auth: AuthConfig{TokenFile: tokenFile}}))
+	assert.Equal(t, "Bearer rotated-token-with-another-length", authorization, "Writes should send the token as well")
+}
+
+func TestFailedCredentialLoadIsReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nws.Close()
+
+	tests := []struct {
+		name     string
+		auth     AuthConfig
+		expError string
+	}{
+		{"missing token file", AuthConfig{TokenFile: filepath.Join(dir, "missing-token")}, "loading the bearer token"},
+		{"empty token file", AuthConfig{TokenFile: writeTestFile(t, dir, "empty-token", []byte("\n"))}, "loading the bearer token"},
+		{"invalid CA bundle", AuthConfig{CAFile: writeTestFile(t, dir, "invalid-ca.pem", []byte("not a certificate"))}, "loading the CA bundle"},
+		{"missing client key", AuthConfig{CertFile: writeTestFile(t, dir, "client-only.crt", []byte("not a certificate")), KeyFile: filepath.Join(dir, "missing.key")}, "loading the client key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewWriter(nws.URL, nil, nil, WriterOptions{Auth: tt.auth}).ConnectivityCheck()
+			assert.Equal(t, "Native writer credentials could not be loaded.", msg)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.expError)
+			}
+		})
+	}
+
+	err = writeWithOptions(t, nws.URL, WriterOptions{Auth: AuthConfig{TokenFile: filepath.Join(dir, "missing-token")}})
+	assert.True(t, errors.Is(err, ErrWriterUnavailable), "A write without credentials should be retryable, got %v", err)
+	assert.Equal(t, 0, calls, "No request should be sent without credentials")
+}
@@ -0,0 +1,76 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned while ingesting native content. Use errors.Is to check which kind of failure occurred,
+// as the returned errors keep the message of the underlying cause.
+var (
+	// ErrInvalidBody means the message cannot be turned into native content, retrying will not help
+	ErrInvalidBody = errors.New("invalid native content body")
+	// ErrUUIDNotFound means none of the configured paths point to a valid UUID in the content body
+	ErrUUIDNotFound = errors.New("UUID not found")
+	// ErrInvalidUUID means the UUID of the content was found but is not accepted, as it is the nil UUID or its version is not allowed
+	ErrInvalidUUID = errors.New("invalid UUID")
+	// ErrRouteNotConfigured means there is no collection for the origin system and content type of the message
+	ErrRouteNotConfigured = errors.New("origin system and content type not configured")
+	// ErrRouteSkipped means the route of the origin system and content type of the message is configured to skip it
+	ErrRouteSkipped = errors.New("origin system and content type skipped")
+	// ErrWriterUnavailable means the native writer could not be reached or failed, so the write may succeed later
+	ErrWriterUnavailable = errors.New("native writer unavailable")
+	// ErrSuperseded means the native store holds a version of the content newer than the one in the message,
+	// which was skipped rather than overwriting it
+	ErrSuperseded = errors.New("native content superseded by a newer version")
+	// ErrWriterRejected means the native writer refused the content, use errors.As with a *WriterRejectedError to get the status code
+	ErrWriterRejected = errors.New("native writer rejected the content")
+)
+
+// WriterRejectedError is returned when the native writer responds with a status code that is not worth retrying
+type WriterRejectedError struct {
+	StatusCode int
+}
+
+func (e *WriterRejectedError) Error() string {
+	return errNon2XXStatusCode
+}
+
+// Is makes a WriterRejectedError match ErrWriterRejected
+func (e *WriterRejectedError) Is(target error) bool {
+	return target == ErrWriterRejected
+}
+
+const errNon2XXStatusCode = "Native writer returned non-200 code"
+
+// classifiedError assigns one of the error kinds above to an error, without changing its message
+type classifiedError struct {
+	kind  error
+	cause error
+}
+
+func (e *classifiedError) Error() string {
+	return e.cause.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.cause
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+func classify(kind error, cause error) error {
+	return &classifiedError{kind: kind, cause: cause}
+}
+
+// NewInvalidBodyError returns an error that matches ErrInvalidBody with the given message
+func NewInvalidBodyError(format string, args ...interface{}) error {
+	return classify(ErrInvalidBody, fmt.Errorf(format, args...))
+}
+
+// IsPermanent returns true if retrying the ingestion of a message that failed with the given error cannot succeed
+func IsPermanent(err error) bool {
+	return err != nil && !errors.Is(err, ErrWriterUnavailable)
+}
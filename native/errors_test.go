@@ -0,0 +1,56 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifiedErrorKeepsCauseMessage(t *testing.T) {
+	cause := errors.New("connection reset by peer")
+	err := classify(ErrWriterUnavailable, cause)
+
+	assert.EqualError(t, err, "connection reset by peer")
+	assert.True(t, errors.Is(err, ErrWriterUnavailable))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrWriterRejected))
+}
+
+func TestWriterRejectedError(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &WriterRejectedError{StatusCode: 422})
+
+	var rejectedErr *WriterRejectedError
+	assert.True(t, errors.As(err, &rejectedErr))
+	assert.Equal(t, 422, rejectedErr.StatusCode)
+	assert.True(t, errors.Is(err, ErrWriterRejected))
+	assert.False(t, errors.Is(err, ErrWriterUnavailable))
+}
+
+func TestNewInvalidBodyError(t *testing.T) {
+	err := NewInvalidBodyError("publish event does not contain %s", "timestamp")
+
+	assert.EqualError(t, err, "publish event does not contain timestamp")
+	assert.True(t, errors.Is(err, ErrInvalidBody))
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"invalid body", classify(ErrInvalidBody, errors.New("bad")), true},
+		{"UUID not found", ErrUUIDNotFound, true},
+		{"route not configured", classify(ErrRouteNotConfigured, errors.New("origin system not found")), true},
+		{"writer rejected", &WriterRejectedError{StatusCode: 400}, true},
+		{"writer unavailable", classify(ErrWriterUnavailable, errors.New("timeout")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsPermanent(tt.err))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileStore keeps each document in the file {dir}/{collection}/{name}, whose modification time is the lastModified of the content
+type fileStore struct {
+	dir string
+}
+
+func (s fileStore) path(collection string, name string) (string, error) {
+	if s.dir == "" {
+		return "", errors.New("no native store directory given")
+	}
+	if collection == "" || collection == "." || collection == ".." || strings.ContainsAny(collection, `/\`) {
+		return "", fmt.Errorf("collection %q is not a valid directory name", collection)
+	}
+	return filepath.Join(s.dir, collection, name), nil
+}
+
+func (s fileStore) read(collection string, name string) (document, bool, error) {
+	path, err := s.path(collection, name)
+	if err != nil {
+		return document{}, false, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return document{}, false, nil
+	}
+	if err != nil {
+		return document{}, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return document{}, false, err
+	}
+	body, err := ioutil.ReadAll(f)
+	if err != nil {
+		return document{}, false, err
+	}
+	return document{body: body, lastModified: info.ModTime()}, true, nil
+}
+
+// write writes the document in a temporary file of the collection directory, which is then renamed over the document
+func (s fileStore) write(collection string, name string, doc document) error {
+	path, err := s.path(collection, name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(doc.body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), doc.lastModified, doc.lastModified); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s fileStore) remove(collection string, name string) (bool, error) {
+	path, err := s.path(collection, name)
+	if err != nil {
+		return false, err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// check creates the directory of the store if needed, and a temporary file in it
+func (s fileStore) check() error {
+	if s.dir == "" {
+		return errors.New("no native store directory given")
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".check.*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
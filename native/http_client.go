@@ -0,0 +1,59 @@
+package native
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPClientConfig defines the timeouts and the connection pool of the HTTP client calling the native writer.
+// As in net/http, a zero timeout means no timeout and a zero pool size means the default size.
+type HTTPClientConfig struct {
+	// Timeout limits the time of a whole request, from dialing to reading the response body, for each attempt
+	Timeout time.Duration
+	// DialTimeout limits the time to establish a connection
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout limits the time of the TLS handshake
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the time to wait for the headers of the response once the request is sent
+	ResponseHeaderTimeout time.Duration
+	// MaxIdleConns is the number of idle connections kept open
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the number of idle connections kept open to the native writer, 2 if zero
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept open
+	IdleConnTimeout time.Duration
+	// KeepAlive is the period of the TCP keep-alive probes, 15s if zero
+	KeepAlive time.Duration
+	// DisableKeepAlives opens a new connection for each request
+	DisableKeepAlives bool
+}
+
+// newHTTPClient returns a client with a transport of its own, configured as given,
+// which authenticates its requests with the credentials, if any
+func newHTTPClient(config HTTPClientConfig, creds *credentials) http.Client {
+	keepAlive := config.KeepAlive
+	if config.DisableKeepAlives {
+		keepAlive = -1
+	}
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: keepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		DisableKeepAlives:     config.DisableKeepAlives,
+		ExpectContinueTimeout: time.Second,
+	}
+	if creds == nil {
+		return http.Client{Transport: transport, Timeout: config.Timeout}
+	}
+	transport.TLSClientConfig = creds.tlsConfig()
+	return http.Client{Transport: &authTransport{transport, creds}, Timeout: config.Timeout}
+}
@@ -0,0 +1,101 @@
+package native
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSlowNativeWriterService returns a server that waits before responding, until it is released
+func setupSlowNativeWriterService(delay time.Duration, beforeHeaders bool) (*httptest.Server, func()) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !beforeHeaders {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-time.After(delay):
+		case <-release:
+		}
+		if beforeHeaders {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write([]byte("{}"))
+	}))
+	return server, func() {
+		close(release)
+		server.Close()
+	}
+}
+
+func TestWriteTimesOut(t *testing.T) {
+	tests := []struct {
+		name          string
+		beforeHeaders bool
+		config        HTTPClientConfig
+	}{
+		{"request timeout before the headers", true, HTTPClientConfig{Timeout: 50 * time.Millisecond}},
+		{"request timeout while reading the body", false, HTTPClientConfig{Timeout: 50 * time.Millisecond}},
+		{"response header timeout", true, HTTPClientConfig{ResponseHeaderTimeout: 50 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nws, stop := setupSlowNativeWriterService(5*time.Second, tt.beforeHeaders)
+			defer stop()
+
+			start := time.Now()
+			err := writeWithOptions(t, nws.URL, WriterOptions{HTTPClient: tt.config})
+			assert.True(t, time.Since(start) < time.Second, "The write should not wait for the slow native writer")
+			assert.True(t, errors.Is(err, ErrWriterUnavailable), "A timed out write should be retryable, got %v", err)
+		})
+	}
+}
+
+func TestWriteWithinTimeout(t *testing.T) {
+	nws, stop := setupSlowNativeWriterService(10*time.Millisecond, true)
+	defer stop()
+
+	err := writeWithOptions(t, nws.URL, WriterOptions{HTTPClient: HTTPClientConfig{Timeout: time.Second, ResponseHeaderTimeout: time.Second}})
+	assert.NoError(t, err)
+}
+
+func TestConnectivityCheckTimesOut(t *testing.T) {
+	nws, stop := setupSlowNativeWriterService(5*time.Second, true)
+	defer stop()
+
+	w := NewWriter(nws.URL, nil, nil, WriterOptions{HTTPClient: HTTPClientConfig{Timeout: 50 * time.Millisecond}})
+	start := time.Now()
+	_, err := w.ConnectivityCheck()
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second, "The check should not wait for the slow native writer")
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	client := newHTTPClient(HTTPClientConfig{
+		Timeout:               10 * time.Second,
+		DialTimeout:           time.Second,
+		TLSHandshakeTimeout:   2 * time.Second,
+		ResponseHeaderTimeout: 3 * time.Second,
+		MaxIdleConns:          50,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       time.Minute,
+		DisableKeepAlives:     true,
+	}, nil)
+
+	assert.Equal(t, 10*time.Second, client.Timeout)
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.True(t, client.Transport != http.DefaultTransport, "The writer should not share the default transport")
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, 3*time.Second, transport.ResponseHeaderTimeout)
+	assert.Equal(t, 50, transport.MaxIdleConns)
+	assert.Equal(t, 20, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.True(t, transport.DisableKeepAlives)
+}
@@ -0,0 +1,65 @@
+package native
+
+import (
+	"sync"
+
+	"github.com/Financial-Times/native-ingester/config"
+)
+
+// MemoryWriter is a Writer keeping native content in memory in place of the native writer service,
+// for tests and for running the ingester locally. The content is lost when the process exits.
+type MemoryWriter struct {
+	*storeWriter
+	store *memoryStore
+}
+
+// NewMemoryWriter returns a MemoryWriter holding no content
+func NewMemoryWriter(collections config.Provider, parser ContentBodyParser, options WriterOptions) *MemoryWriter {
+	store := &memoryStore{documents: make(map[string]document)}
+	return &MemoryWriter{newStoreWriter(collections, parser, store, options), store}
+}
+
+// Content returns the native content of the collection with the given UUID, and whether the writer holds it
+func (w *MemoryWriter) Content(collection string, contentUUID string) (string, bool) {
+	for _, ext := range documentExtensions {
+		if doc, found, _ := w.store.read(collection, contentUUID+ext); found {
+			return string(doc.body), true
+		}
+	}
+	return "", false
+}
+
+// memoryStore keeps the documents in a map, by collection and name
+type memoryStore struct {
+	mutex     sync.RWMutex
+	documents map[string]document
+}
+
+func (s *memoryStore) read(collection string, name string) (document, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	doc, found := s.documents[collection+"/"+name]
+	return doc, found, nil
+}
+
+func (s *memoryStore) write(collection string, name string, doc document) error {
+	body := make([]byte, len(doc.body))
+	copy(body, doc.body)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.documents[collection+"/"+name] = document{body: body, lastModified: doc.lastModified}
+	return nil
+}
+
+func (s *memoryStore) remove(collection string, name string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, found := s.documents[collection+"/"+name]
+	delete(s.documents, collection+"/"+name)
+	return found, nil
+}
+
+func (s *memoryStore) check() error {
+	return nil
+}
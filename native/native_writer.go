@@ -2,16 +2,20 @@ package native
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/metrics"
+	"github.com/Financial-Times/native-ingester/xpath"
 	"github.com/Financial-Times/service-status-go/httphandlers"
+	uuidParser "github.com/satori/go.uuid"
 )
 
 const (
@@ -21,56 +25,245 @@ const (
 	originSystemIDHeader               = "Origin-System-Id"
 	messageTypeHeader                  = "Message-Type"
 	messageTypePartialContentPublished = "cms-partial-content-published"
+	messageTypeContentDeleted          = "cms-content-deleted"
+	contentUUIDHeader                  = "Content-UUID"
+	ifUnmodifiedSinceHeader            = "X-If-Unmodified-Since"
+	lastModifiedHeader                 = "X-Last-Modified"
+	publishReferenceHeader             = "X-Publish-Reference"
 )
 
 // Writer provides the functionalities to write in the native store
 type Writer interface {
 	GetCollection(originID string, contentType string) (string, error)
-	WriteToCollection(msg NativeMessage, collection string) (string, string, error)
+	WriteToCollection(msg NativeMessage, collection string) (WriteResult, error)
 	ConnectivityCheck() (string, error)
 }
 
+// dryRunner is implemented by the writers that can skip their writes, only logging and counting them
+type dryRunner interface {
+	isDryRun() bool
+}
+
+// IsDryRun returns true if the writer skips its writes, only logging and counting them
+func IsDryRun(w Writer) bool {
+	d, ok := w.(dryRunner)
+	return ok && d.isDryRun()
+}
+
+// WriteResult describes the native content written by a Writer
+type WriteResult struct {
+	// UUID is the UUID of the native content, also set when the write fails after the UUID is found
+	UUID string
+	// DerivedUUID is true when the content has no UUID and it was derived from another identifier
+	DerivedUUID bool
+	// UpdatedContent is the content returned by the native writer
+	UpdatedContent string
+}
+
 type nativeWriter struct {
+	contentRouter
 	address     string
-	collections config.Configuration
 	httpClient  http.Client
+	options     WriterOptions
+	credentials *credentials
+}
+
+// contentRouter resolves the collection and the UUID of native content with the routing configuration,
+// whatever the store the content is written to
+type contentRouter struct {
+	collections config.Provider
 	bodyParser  ContentBodyParser
 }
 
-// NewWriter returns a new instance of a native writer
-func NewWriter(address string, collectionsOriginIdsMap config.Configuration, parser ContentBodyParser) Writer {
-	collections := collectionsOriginIdsMap
-	return &nativeWriter{address, collections, http.Client{}, parser}
+// WriterOptions defines how the native writer is called
+type WriterOptions struct {
+	RetryPolicy RetryPolicy
+	// HTTPClient configures the client of both the writes and the connectivity check
+	HTTPClient HTTPClientConfig
+	// Auth defines the credentials of every request to the native writer
+	Auth AuthConfig
+	// ConditionalWrites asks the native writer to keep the content it holds if it is newer than the message
+	ConditionalWrites bool
+	// DryRun logs and counts the calls to the native writer without making them
+	DryRun bool
+	// Breaker stops calling the native writer while it is failing, nil if there is no circuit breaker
+	Breaker *CircuitBreaker
 }
 
-func (nw *nativeWriter) GetCollection(originID string, contentType string) (string, error) {
-	return nw.collections.GetCollection(originID, contentType)
+// NewWriter returns a new instance of a native writer, whose store is selected by the scheme of the address:
+// file://{directory} writes in a directory with a fileStore, mem:// keeps the content in memory as a MemoryWriter,
+// and any other address is the URL of the native writer service.
+// The collections are resolved with the configuration currently held by the given provider.
+func NewWriter(address string, collections config.Provider, parser ContentBodyParser, options WriterOptions) Writer {
+	switch {
+	case strings.HasPrefix(address, fileScheme):
+		return newStoreWriter(collections, parser, fileStore{strings.TrimPrefix(address, fileScheme)}, options)
+	case strings.HasPrefix(address, memoryScheme):
+		return NewMemoryWriter(collections, parser, options)
+	}
+
+	var creds *credentials
+	if options.Auth.enabled() {
+		creds = newCredentials(options.Auth)
+	}
+	return &nativeWriter{contentRouter{collections, parser}, address, newHTTPClient(options.HTTPClient, creds), options, creds}
 }
 
-func (nw *nativeWriter) WriteToCollection(msg NativeMessage, collection string) (string, string, error) {
-	contentUUID, err := nw.bodyParser.getUUID(msg.body)
+func (r contentRouter) GetCollection(originID string, contentType string) (string, error) {
+	collection, err := r.collections.Current().GetCollection(originID, contentType)
+	if errors.Is(err, config.ErrRouteSkipped) {
+		return "", classify(ErrRouteSkipped, err)
+	}
+	if err != nil {
+		return "", classify(ErrRouteNotConfigured, err)
+	}
+	return collection, nil
+}
+
+func (nw *nativeWriter) WriteToCollection(msg NativeMessage, collection string) (WriteResult, error) {
+	contentUUID, derived, err := nw.resolveUUID(msg)
 	if err != nil {
 		logger.NewEntry(msg.TransactionID()).WithError(err).Error("Error extracting uuid. Ignoring message.")
-		return contentUUID, "", err
+		return WriteResult{}, err
+	}
+	result := WriteResult{UUID: contentUUID, DerivedUUID: derived}
+	if derived {
+		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Derived the UUID of content without UUID")
 	}
 	logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Start processing native publish event")
-	cBodyAsJSON, err := json.Marshal(msg.body)
 
+	requestURL := nw.address + "/" + collection + "/" + contentUUID
+	httpMethod := writeMethod(msg)
+	var cBodyAsJSON []byte
+
+	if !msg.IsDelete() {
+		cBodyAsJSON = msg.rawBody
+	}
+
+	newRequest := func() (*http.Request, error) {
+		return nw.buildRequest(msg, contentUUID, httpMethod, requestURL, cBodyAsJSON)
+	}
+	if nw.options.DryRun {
+		return result, nw.dryRun(msg, contentUUID, collection, newRequest)
+	}
+
+	response, err := nw.doWithBreaker(msg, contentUUID, collection, newRequest)
 	if err != nil {
-		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err).Error("Error marshalling message")
-		return contentUUID, "", err
+		entry := logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err)
+		var rejectedErr *WriterRejectedError
+		if errors.Is(err, ErrSuperseded) {
+			entry.WithField("lastModified", msg.timestamp).Info("Native store holds a newer version of the content. Skipping message.")
+		} else if errors.As(err, &rejectedErr) {
+			entry.WithField("statusCode", rejectedErr.StatusCode).Error("Native writer rejected the content. Ignoring message.")
+		} else {
+			entry.Error("Error calling native writer. Ignoring message.")
+		}
+		return result, err
 	}
+	defer properClose(msg.TransactionID(), response)
 
-	requestURL := nw.address + "/" + collection + "/" + contentUUID
-	httpMethod := "PUT"
+	if msg.IsDelete() && response.StatusCode == http.StatusNotFound {
+		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Native content was not found, nothing to delete")
+		return result, nil
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err).Error("Error reading the response of the native writer")
+		return result, classify(ErrWriterUnavailable, err)
+	}
+	result.UpdatedContent = string(body)
+	logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Successfully finished processing native publish event")
+	return result, nil
+}
+
+// ContentUUID returns the UUID of the native content, found in a JSON or XML body by the parser,
+// or else taken from the Content-UUID header, as for binary content or a delete message without a body
+func ContentUUID(msg NativeMessage, parser ContentBodyParser) (string, error) {
+	switch {
+	case msg.body != nil:
+		return parser.getUUID(msg.body)
+	case msg.xmlBody != nil:
+		return parser.getXMLUUID(msg.xmlBody)
+	default:
+		return normaliseUUID(msg.headers[contentUUIDHeader])
+	}
+}
+
+// ResolveContentUUID returns the UUID of the native content as the writers resolve it with the routing configuration
+// currently held by the provider, and whether the UUID was derived, see resolveUUID
+func ResolveContentUUID(msg NativeMessage, collections config.Provider, parser ContentBodyParser) (string, bool, error) {
+	return contentRouter{collections, parser}.resolveUUID(msg)
+}
+
+// resolveUUID returns the UUID of the native content, found with the UUID fields configured for the route of the message
+// or the global ones, or else derived as configured for the route
+func (r contentRouter) resolveUUID(msg NativeMessage) (string, bool, error) {
+	route, routeErr := r.collections.Current().GetRoute(strings.TrimSpace(msg.OriginSystemID()), msg.ContentType())
+	if routeErr != nil {
+		route = nil
+	}
+
+	parser := r.bodyParser
+	if route != nil {
+		parser = routeBodyParser{parser, route}
+	}
+	contentUUID, err := ContentUUID(msg, parser)
+	if err == nil && route != nil {
+		err = checkUUIDVersion(contentUUID, route.UUIDVersions)
+	}
+	if err == nil || !errors.Is(err, ErrUUIDNotFound) || route == nil || route.UUIDDerivation == nil || msg.body == nil {
+		return contentUUID, false, err
+	}
+
+	derived, found := route.UUIDDerivation.Derive(msg.body)
+	if !found {
+		return "", false, err
+	}
+	if err := checkUUIDVersion(derived, route.UUIDVersions); err != nil {
+		return "", false, err
+	}
+	return derived, true, nil
+}
+
+// writeMethod returns the method of the request writing the message to the native writer
+func writeMethod(msg NativeMessage) string {
+	switch {
+	case msg.IsDelete():
+		return "DELETE"
+	case msg.IsPartialContent():
+		return "PATCH"
+	}
+	return "PUT"
+}
+
+// checkUUIDVersion fails with ErrInvalidUUID if the version of the UUID is not one of the given versions, unless none are given
+func checkUUIDVersion(contentUUID string, versions []int) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	version := int(uuidParser.FromStringOrNil(contentUUID).Version())
+	for _, v := range versions {
+		if v == version {
+			return nil
+		}
+	}
+	return classify(ErrInvalidUUID, fmt.Errorf("UUID %v has version %v, accepted versions are %v", contentUUID, version, versions))
+}
+
+// isDryRun returns true if the calls to the native writer are only logged and counted, without being made
+func (nw *nativeWriter) isDryRun() bool {
+	return nw.options.DryRun
+}
 
-	if msg.IsPartialContent() {
-		httpMethod = "PATCH"
+func (nw *nativeWriter) buildRequest(msg NativeMessage, contentUUID string, httpMethod string, requestURL string, body []byte) (*http.Request, error) {
+	var requestBody io.Reader = http.NoBody
+	if body != nil {
+		requestBody = bytes.NewReader(body)
 	}
-	request, err := http.NewRequest(httpMethod, requestURL, bytes.NewBuffer(cBodyAsJSON))
+	request, err := http.NewRequest(httpMethod, requestURL, requestBody)
 	if err != nil {
-		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err).Error("Error calling native writer. Ignoring message.")
-		return contentUUID, "", err
+		return nil, err
 	}
 
 	for header, value := range msg.headers {
@@ -91,25 +284,127 @@ func (nw *nativeWriter) WriteToCollection(msg NativeMessage, collection string)
 			WithUUID(contentUUID).
 			Warn("Native-save request does not have Origin-System-ID header")
 	}
-	response, err := nw.httpClient.Do(request)
 
+	if nw.options.ConditionalWrites {
+		if lastModified, err := time.Parse(time.RFC3339Nano, msg.timestamp); err == nil {
+			// unlike the HTTP date of If-Unmodified-Since, the timestamp keeps its sub-second precision,
+			// so that a newer version published within the same second is not taken for an older one
+			request.Header.Set(ifUnmodifiedSinceHeader, lastModified.UTC().Format(time.RFC3339Nano))
+		} else {
+			logger.NewEntry(msg.TransactionID()).
+				WithUUID(contentUUID).
+				WithError(err).
+				Warn("Native-save request cannot be conditional, the message timestamp is not valid")
+		}
+	}
+	return request, nil
+}
+
+// dryRun builds the request to the native writer, then logs and counts it instead of sending it
+func (nw *nativeWriter) dryRun(msg NativeMessage, contentUUID string, collection string, newRequest func() (*http.Request, error)) error {
+	request, err := newRequest()
 	if err != nil {
-		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err).Error("Error calling native writer. Ignoring message.")
-		return contentUUID, "", err
+		return err
 	}
-	defer properClose(msg.TransactionID(), response)
+	metrics.DryRunWrites.WithLabelValues(collection, request.Method).Inc()
+	logger.NewEntry(msg.TransactionID()).
+		WithUUID(contentUUID).
+		WithField("collection", collection).
+		WithField("method", request.Method).
+		WithField("url", request.URL.String()).
+		Infof("Dry run: skipping call to native writer: %v %v", request.Method, request.URL)
+	return nil
+}
 
-	if isNot2XXStatusCode(response.StatusCode) {
-		errMsg := "Native writer returned non-200 code"
-		err := errors.New(errMsg)
-		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err).Error(errMsg)
-		return contentUUID, "", err
+// doWithBreaker sends the request with retries once the circuit breaker of the writer, if any, lets it through
+func (nw *nativeWriter) doWithBreaker(msg NativeMessage, contentUUID string, collection string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	breaker := nw.options.Breaker
+	if breaker == nil {
+		return nw.doWithRetry(msg, contentUUID, collection, newRequest)
+	}
+	probe := breaker.acquire()
+	if probe {
+		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Probing the native writer for the half-open circuit breaker")
 	}
+	response, err := nw.doWithRetry(msg, contentUUID, collection, newRequest)
+	breaker.record(probe, err)
+	return response, err
+}
 
-	body, err := ioutil.ReadAll(response.Body)
-	updatedContent := string(body)
-	logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Successfully finished processing native publish event")
-	return contentUUID, updatedContent, nil
+// doWithRetry sends the request built by newRequest according to the retry policy of the writer.
+// Only failures classified as ErrWriterUnavailable are retried.
+func (nw *nativeWriter) doWithRetry(msg NativeMessage, contentUUID string, collection string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	maxAttempts := nw.options.RetryPolicy.attempts()
+	for attempt := 1; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := logger.NewEntry(msg.TransactionID()).
+			WithUUID(contentUUID).
+			WithField("attempt", attempt).
+			WithField("maxAttempts", maxAttempts)
+		entry.Infof("Calling native writer: %v %v", request.Method, request.URL)
+
+		start := time.Now()
+		response, err := nw.httpClient.Do(request)
+		metrics.NativeWriterRequestDuration.WithLabelValues(collection, request.Method).Observe(time.Since(start).Seconds())
+		err = checkResponse(request, response, err)
+		if err == nil {
+			return response, nil
+		}
+
+		entry = entry.WithError(err)
+		if response != nil {
+			entry = entry.WithField("statusCode", response.StatusCode)
+		}
+
+		retryable := errors.Is(err, ErrWriterUnavailable)
+		var delay time.Duration
+		if retryable && attempt < maxAttempts {
+			delay = nw.options.RetryPolicy.delay(attempt, response)
+		}
+		if response != nil {
+			properClose(msg.TransactionID(), response)
+		}
+
+		if errors.Is(err, ErrSuperseded) {
+			return nil, err
+		}
+		if !retryable {
+			entry.Warn("Native writer call failed, the request will not be retried")
+			return nil, err
+		}
+		if attempt >= maxAttempts {
+			entry.Warn("Native writer call failed, no attempts left")
+			return nil, err
+		}
+
+		entry.Warnf("Native writer call failed, retrying in %v", delay)
+		time.Sleep(delay)
+	}
+}
+
+// checkResponse classifies the outcome of a call to the native writer.
+// Deleting content that is not in the native store is not a failure.
+func checkResponse(request *http.Request, response *http.Response, err error) error {
+	if err != nil {
+		return classify(ErrWriterUnavailable, err)
+	}
+	if !isNot2XXStatusCode(response.StatusCode) {
+		return nil
+	}
+	if request.Method == "DELETE" && response.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if request.Header.Get(ifUnmodifiedSinceHeader) != "" && isPreconditionFailedStatusCode(response.StatusCode) {
+		return classify(ErrSuperseded, fmt.Errorf("native writer returned %v to a conditional write", response.StatusCode))
+	}
+	if isRetryableStatusCode(response.StatusCode) {
+		return classify(ErrWriterUnavailable, errors.New(errNon2XXStatusCode))
+	}
+	return &WriterRejectedError{StatusCode: response.StatusCode}
 }
 
 func properClose(tid string, resp *http.Response) {
@@ -127,7 +422,16 @@ func isNot2XXStatusCode(statusCode int) bool {
 	return statusCode < 200 || statusCode >= 300
 }
 
+func isPreconditionFailedStatusCode(statusCode int) bool {
+	return statusCode == http.StatusConflict || statusCode == http.StatusPreconditionFailed
+}
+
 func (nw nativeWriter) ConnectivityCheck() (string, error) {
+	if nw.credentials != nil {
+		if err := nw.credentials.check(); err != nil {
+			return "Native writer credentials could not be loaded.", err
+		}
+	}
 	req, err := http.NewRequest("GET", nw.address+httphandlers.GTGPath, nil)
 	if err != nil {
 		return "Error in building request to check if the native writer is good to go", err
@@ -148,24 +452,36 @@ func (nw nativeWriter) ConnectivityCheck() (string, error) {
 
 // NativeMessage is the message accepted by the native writer
 type NativeMessage struct {
-	body    map[string]interface{}
-	headers map[string]string
+	// body is the decoded JSON body, nil for other formats
+	body map[string]interface{}
+	// xmlBody is the parsed XML body, nil for other formats
+	xmlBody   *xpath.Node
+	rawBody   []byte
+	headers   map[string]string
+	timestamp string
 }
 
-// NewNativeMessage returns a new instance of a NativeMessage
-func NewNativeMessage(contentBody string, timestamp string, transactionID string, messageType string) (NativeMessage, error) {
-	body := make(map[string]interface{})
-	if err := json.Unmarshal([]byte(contentBody), &body); err != nil {
-		return NativeMessage{}, err
-	}
-
-	body["lastModified"] = timestamp
-	body["publishReference"] = transactionID
-
-	msg := NativeMessage{body, make(map[string]string)}
+// NewNativeMessage returns a new instance of a NativeMessage, whose body is decoded by the handler of its content type.
+// An empty content body is accepted only for a message deleting the content, see IsDelete, so that a message published
+// with an empty body by mistake is rejected rather than deleting the content.
+func NewNativeMessage(contentType string, contentBody string, timestamp string, transactionID string, messageType string) (NativeMessage, error) {
+	msg := NativeMessage{headers: make(map[string]string), timestamp: timestamp}
 	msg.headers[transactionIDHeader] = transactionID
 	msg.headers[messageTypeHeader] = messageType
+	if contentType != "" {
+		msg.headers[contentTypeHeader] = contentType
+	}
+
+	if strings.TrimSpace(contentBody) == "" {
+		if !msg.IsDelete() {
+			return NativeMessage{}, NewInvalidBodyError("empty body in a %q message, only %q messages may have no body", messageType, messageTypeContentDeleted)
+		}
+		return msg, nil
+	}
 
+	if err := bodyHandlerFor(contentType).decode(&msg, contentBody, timestamp, transactionID); err != nil {
+		return NativeMessage{}, classify(ErrInvalidBody, err)
+	}
 	return msg, nil
 }
 
@@ -183,6 +499,11 @@ func (msg *NativeMessage) AddOriginSystemIDHeader(hash string) {
 	msg.headers[originSystemIDHeader] = hash
 }
 
+// AddContentUUIDHeader adds the UUID of the native content as a header, used to delete content when the message has no body
+func (msg *NativeMessage) AddContentUUIDHeader(uuid string) {
+	msg.headers[contentUUIDHeader] = uuid
+}
+
 func (msg *NativeMessage) TransactionID() string {
 	return msg.headers[transactionIDHeader]
 }
@@ -198,3 +519,8 @@ func (msg *NativeMessage) OriginSystemID() string {
 func (msg *NativeMessage) IsPartialContent() bool {
 	return msg.headers[messageTypeHeader] == messageTypePartialContentPublished
 }
+
+// IsDelete returns true if the message type is the one deleting the content, whatever its body
+func (msg *NativeMessage) IsDelete() bool {
+	return msg.headers[messageTypeHeader] == messageTypeContentDeleted
+}
@@ -1,17 +1,25 @@
 package native
 
 import (
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/metrics"
+	"github.com/Financial-Times/native-ingester/xpath"
 	"github.com/Financial-Times/service-status-go/httphandlers"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -106,7 +114,7 @@ func TestGetCollectionShort(t *testing.T) {
 	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
 	assert.NoError(t, err, "It should not return an error")
 
-	w := NewWriter("", *testCollectionsOriginIdsMap, p)
+	w := NewWriter("", testCollectionsOriginIdsMap, p, WriterOptions{})
 
 	actualCollection, err := w.GetCollection(methodeOriginSystemID, aContentType)
 	assert.NoError(t, err, "It should not return an error")
@@ -121,7 +129,7 @@ func TestGetCollection(t *testing.T) {
 	p := new(ContentBodyParserMock)
 	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
 	assert.NoError(t, err, "It should not return an error")
-	w := NewWriter("", *testCollectionsOriginIdsMap, p)
+	w := NewWriter("", testCollectionsOriginIdsMap, p, WriterOptions{})
 
 	tests := []struct {
 		contentType   string
@@ -171,7 +179,7 @@ func TestGetAllCollection(t *testing.T) {
 	}`
 	testCollectionsOriginIdsMap, err := getConfig(str)
 	assert.NoError(t, err, "It should not return an error")
-	w := NewWriter("", *testCollectionsOriginIdsMap, p)
+	w := NewWriter("", testCollectionsOriginIdsMap, p, WriterOptions{})
 
 	tests := []struct {
 		contentType   string
@@ -212,7 +220,7 @@ func TestGetVideoCollection(t *testing.T) {
 	p := new(ContentBodyParserMock)
 	testCollectionsOriginIdsMap, err := getConfig(audioStrCollectionsOriginIdsMap)
 	assert.NoError(t, err, "It should not return an error")
-	w := NewWriter("", *testCollectionsOriginIdsMap, p)
+	w := NewWriter("", testCollectionsOriginIdsMap, p, WriterOptions{})
 	o := "http://cmdb.ft.com/systems/next-video-editor"
 
 	tests := []struct {
@@ -271,15 +279,15 @@ func TestWriteMessageToCollectionWithSuccess(t *testing.T) {
 	nws := setupMockNativeWriterService(t, 200, withoutNativeHashHeader, "PUT", methodeCollectionName)
 	defer nws.Close()
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
 	msg.AddContentTypeHeader(aContentType)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
-	contentUUID, _, err := w.WriteToCollection(msg, methodeCollectionName)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
 
 	assert.NoError(t, err, "It should not return an error")
-	assert.Equal(t, aUUID, contentUUID)
+	assert.Equal(t, aUUID, result.UUID)
 	p.AssertExpectations(t)
 }
 
@@ -291,15 +299,15 @@ func TestWritePartialMessageToCollectionWithSuccess(t *testing.T) {
 	nws := setupMockNativeWriterService(t, 200, withoutNativeHashHeader, "PATCH", universalContentCollectionName)
 	defer nws.Close()
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypePartialContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypePartialContentPublished)
 	msg.AddContentTypeHeader(aContentType)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
-	contentUUID, _, err := w.WriteToCollection(msg, universalContentCollectionName)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	result, err := w.WriteToCollection(msg, universalContentCollectionName)
 
 	assert.NoError(t, err, "It should not return an error")
-	assert.Equal(t, aUUID, contentUUID)
+	assert.Equal(t, aUUID, result.UUID)
 	p.AssertExpectations(t)
 }
 
@@ -312,16 +320,16 @@ func TestWriteMessageWithHashToCollectionWithSuccess(t *testing.T) {
 	nws := setupMockNativeWriterService(t, 200, withNativeHashHeader, "PUT", methodeCollectionName)
 	defer nws.Close()
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 	msg.AddHashHeader(aHash)
 	msg.AddContentTypeHeader(aContentType)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
-	contentUUID, _, err := w.WriteToCollection(msg, methodeCollectionName)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
 
 	assert.NoError(t, err, "It should not return an error")
-	assert.Equal(t, aUUID, contentUUID)
+	assert.Equal(t, aUUID, result.UUID)
 	p.AssertExpectations(t)
 }
 
@@ -334,16 +342,16 @@ func TestWriteMessageToCollectionWithContentTypeSuccess(t *testing.T) {
 	nws := setupMockNativeWriterService(t, 200, withNativeHashHeader, "PUT", methodeCollectionName)
 	defer nws.Close()
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 	msg.AddHashHeader(aHash)
 	msg.AddContentTypeHeader(aContentType)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
-	contentUUID, _, err := w.WriteToCollection(msg, methodeCollectionName)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
 
 	assert.NoError(t, err, "It should not return an error")
-	assert.Equal(t, aUUID, contentUUID)
+	assert.Equal(t, aUUID, result.UUID)
 	p.AssertExpectations(t)
 }
 
@@ -356,12 +364,12 @@ func TestWriteContentBodyToCollectionFailBecauseOfMissingUUID(t *testing.T) {
 	nws := setupMockNativeWriterService(t, 200, withNativeHashHeader, "PUT", methodeCollectionName)
 	defer nws.Close()
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 	msg.AddHashHeader(aHash)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
-	_, _, err = w.WriteToCollection(msg, methodeCollectionName)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
 
 	assert.EqualError(t, err, "UUID not found", "It should return a  UUID not found error")
 	p.AssertExpectations(t)
@@ -376,13 +384,13 @@ func TestWriteContentBodyToCollectionFailBecauseOfNativeRWServiceInternalError(t
 	nws := setupMockNativeWriterService(t, 500, withoutNativeHashHeader, "PUT", methodeCollectionName)
 	defer nws.Close()
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 	msg.AddHashHeader(aHash)
 	msg.AddContentTypeHeader(aContentType)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
-	_, _, err = w.WriteToCollection(msg, methodeCollectionName)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
 
 	assert.EqualError(t, err, "Native writer returned non-200 code", "It should return a non-200 HTTP status error")
 	p.AssertExpectations(t)
@@ -395,13 +403,13 @@ func TestWriteContentBodyToCollectionFailBecauseOfNativeRWServiceNotAvailable(t
 
 	p.On("getUUID", aContentBody).Return(aUUID, nil)
 
-	msg, err := NewNativeMessage("{}", aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
 	assert.NoError(t, err, "It should not return an error by creating a message")
 	msg.AddHashHeader(aHash)
 	msg.AddContentTypeHeader(aContentType)
 
-	w := NewWriter("http://an-address.com", *testCollectionsOriginIdsMap, p)
-	_, _, err = w.WriteToCollection(msg, methodeCollectionName)
+	w := NewWriter("http://an-address.com", testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
 
 	assert.Error(t, err, "It should return an error")
 	p.AssertExpectations(t)
@@ -414,7 +422,7 @@ func TestConnectivityCheckSuccess(t *testing.T) {
 
 	nws := setupMockNativeWriterGTG(t, 200)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
 	msg, err := w.ConnectivityCheck()
 
 	assert.NoError(t, err, "It should not return an error")
@@ -428,7 +436,7 @@ func TestConnectivityCheckSuccessWithoutHostHeader(t *testing.T) {
 
 	nws := setupMockNativeWriterGTG(t, 200)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
 	msg, err := w.ConnectivityCheck()
 
 	assert.NoError(t, err, "It should not return an error")
@@ -442,7 +450,7 @@ func TestConnectivityCheckFailNotGTG(t *testing.T) {
 
 	nws := setupMockNativeWriterGTG(t, 503)
 
-	w := NewWriter(nws.URL, *testCollectionsOriginIdsMap, p)
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
 	msg, err := w.ConnectivityCheck()
 
 	assert.EqualError(t, err, "GTG HTTP status code is 503", "It should return an error")
@@ -454,7 +462,7 @@ func TestConnectivityCheckFailNativeRWServiceNotAvailable(t *testing.T) {
 	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
 	assert.NoError(t, err, "It should not return an error")
 
-	w := NewWriter("http://an-address.com", *testCollectionsOriginIdsMap, p)
+	w := NewWriter("http://an-address.com", testCollectionsOriginIdsMap, p, WriterOptions{})
 	msg, err := w.ConnectivityCheck()
 
 	assert.Error(t, err, "It should return an error")
@@ -466,7 +474,7 @@ func TestConnectivityCheckFailToBuildRequest(t *testing.T) {
 	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
 	assert.NoError(t, err, "It should not return an error")
 
-	w := NewWriter("http://foo.com  and some spaces", *testCollectionsOriginIdsMap, p)
+	w := NewWriter("http://foo.com  and some spaces", testCollectionsOriginIdsMap, p, WriterOptions{})
 	msg, err := w.ConnectivityCheck()
 
 	assert.Error(t, err, "It should return an error")
@@ -482,8 +490,13 @@ func (p *ContentBodyParserMock) getUUID(body map[string]interface{}) (string, er
 	return args.String(0), args.Error(1)
 }
 
+func (p *ContentBodyParserMock) getXMLUUID(doc *xpath.Node) (string, error) {
+	args := p.Called(doc)
+	return args.String(0), args.Error(1)
+}
+
 func TestBuildNativeMessageSuccess(t *testing.T) {
-	msg, err := NewNativeMessage(`{"foo":"bar"}`, aTimestamp, publishRef, messageTypeContentPublished)
+	msg, err := NewNativeMessage("", `{"foo":"bar"}`, aTimestamp, publishRef, messageTypeContentPublished)
 	assert.NoError(t, err, "It should return an error in creating a new message")
 	msg.AddHashHeader(aHash)
 
@@ -495,6 +508,617 @@ func TestBuildNativeMessageSuccess(t *testing.T) {
 }
 
 func TestBuildNativeMessageFailure(t *testing.T) {
-	_, err := NewNativeMessage("__INVALID_BODY__", aTimestamp, publishRef, messageTypeContentPublished)
+	_, err := NewNativeMessage("", "__INVALID_BODY__", aTimestamp, publishRef, messageTypeContentPublished)
 	assert.EqualError(t, err, "invalid character '_' looking for beginning of value", "It should return an error in creating a new message")
 }
+
+func TestGetCollectionErrorIsRouteNotConfigured(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+	w := NewWriter("", testCollectionsOriginIdsMap, p, WriterOptions{})
+
+	_, err = w.GetCollection("Origin-Id-that-do-not-exist", aContentType)
+	assert.True(t, errors.Is(err, ErrRouteNotConfigured), "It should return a route not configured error")
+
+	_, err = w.GetCollection(methodeOriginSystemID, "wrong")
+	assert.True(t, errors.Is(err, ErrRouteNotConfigured), "It should return a route not configured error")
+}
+
+func TestWriteContentBodyToCollectionFailBecauseOfNativeRWServiceRejection(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+
+	p.On("getUUID", aContentBody).Return(aUUID, nil)
+	nws := setupMockNativeWriterService(t, 400, withoutNativeHashHeader, "PUT", methodeCollectionName)
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+	msg.AddContentTypeHeader(aContentType)
+
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+
+	var rejectedErr *WriterRejectedError
+	assert.True(t, errors.As(err, &rejectedErr), "It should return a writer rejected error")
+	assert.Equal(t, 400, rejectedErr.StatusCode)
+	assert.True(t, errors.Is(err, ErrWriterRejected))
+	p.AssertExpectations(t)
+}
+
+func TestWriteContentBodyToCollectionErrorIsWriterUnavailable(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+
+	p.On("getUUID", aContentBody).Return(aUUID, nil)
+	nws := setupMockNativeWriterService(t, 503, withoutNativeHashHeader, "PUT", methodeCollectionName)
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+	msg.AddContentTypeHeader(aContentType)
+
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+	assert.True(t, errors.Is(err, ErrWriterUnavailable), "It should return a writer unavailable error")
+
+	w = NewWriter("http://an-address.com", testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+	assert.True(t, errors.Is(err, ErrWriterUnavailable), "It should return a writer unavailable error")
+}
+
+func TestBuildNativeMessageFailureIsInvalidBody(t *testing.T) {
+	_, err := NewNativeMessage("", "__INVALID_BODY__", aTimestamp, publishRef, messageTypeContentPublished)
+	assert.True(t, errors.Is(err, ErrInvalidBody), "It should return an invalid body error")
+}
+
+func TestDeleteMessageFromCollection(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"deleted", http.StatusNoContent},
+		{"ok", http.StatusOK},
+		{"not found", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := new(ContentBodyParserMock)
+			testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+			assert.NoError(t, err, "It should not return an error")
+			p.On("getUUID", aContentBody).Return(aUUID, nil)
+			nws := setupMockNativeWriterService(t, tt.status, withoutNativeHashHeader, "DELETE", methodeCollectionName)
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentDeleted)
+			assert.NoError(t, err, "It should not return an error by creating a message")
+			msg.AddContentTypeHeader(aContentType)
+
+			w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+			result, err := w.WriteToCollection(msg, methodeCollectionName)
+
+			assert.NoError(t, err, "It should not return an error")
+			assert.Equal(t, aUUID, result.UUID)
+			assert.Empty(t, result.UpdatedContent)
+			p.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteMessageWithEmptyBodyFromCollection(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "DELETE", req.Method)
+		assert.Equal(t, "/"+methodeCollectionName+"/"+aUUID, req.URL.Path)
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Empty(t, body, "It should not send a body")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("", "", aTimestamp, publishRef, messageTypeContentDeleted)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+	msg.AddContentTypeHeader(aContentType)
+	msg.AddContentUUIDHeader(aUUID)
+
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
+
+	assert.NoError(t, err, "It should not return an error")
+	assert.Equal(t, aUUID, result.UUID)
+	p.AssertExpectations(t)
+}
+
+func TestDeleteMessageWithEmptyBodyFailBecauseOfMissingUUID(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+
+	msg, err := NewNativeMessage("", " ", aTimestamp, publishRef, messageTypeContentDeleted)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+
+	w := NewWriter("http://an-address.com", testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+
+	assert.True(t, errors.Is(err, ErrUUIDNotFound), "It should return a UUID not found error")
+	p.AssertExpectations(t)
+}
+
+func TestDeleteMessageFailBecauseOfNativeRWServiceInternalError(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+	p.On("getUUID", aContentBody).Return(aUUID, nil)
+	nws := setupMockNativeWriterService(t, 500, withoutNativeHashHeader, "DELETE", methodeCollectionName)
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentDeleted)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+	msg.AddContentTypeHeader(aContentType)
+
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+
+	assert.True(t, errors.Is(err, ErrWriterUnavailable), "It should return a writer unavailable error")
+}
+
+func TestIsDelete(t *testing.T) {
+	tests := []struct {
+		body        string
+		messageType string
+		want        bool
+	}{
+		{`{"foo":"bar"}`, messageTypeContentPublished, false},
+		{`{"foo":"bar"}`, messageTypePartialContentPublished, false},
+		{`{"foo":"bar"}`, messageTypeContentDeleted, true},
+		{"", messageTypeContentDeleted, true},
+		{"  \n", messageTypeContentDeleted, true},
+	}
+	for _, tt := range tests {
+		msg, err := NewNativeMessage("", tt.body, aTimestamp, publishRef, tt.messageType)
+		assert.NoError(t, err, "It should not return an error by creating a message")
+		assert.Equal(t, tt.want, msg.IsDelete(), "Unexpected delete flag for body %q and message type %q", tt.body, tt.messageType)
+	}
+}
+
+func TestEmptyBodyIsRejectedUnlessDeleting(t *testing.T) {
+	for _, messageType := range []string{messageTypeContentPublished, messageTypePartialContentPublished, ""} {
+		for _, body := range []string{"", "  \n"} {
+			_, err := NewNativeMessage("", body, aTimestamp, publishRef, messageType)
+			assert.True(t, errors.Is(err, ErrInvalidBody), "An empty %q message should not delete the content, got %v", messageType, err)
+		}
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	tests := []struct {
+		name              string
+		conditionalWrites bool
+		status            int
+		expHeader         string
+		expErr            error
+	}{
+		{"unconditional", false, http.StatusOK, "", nil},
+		{"written", true, http.StatusOK, aTimestamp, nil},
+		{"superseded with conflict", true, http.StatusConflict, aTimestamp, ErrSuperseded},
+		{"superseded with precondition failed", true, http.StatusPreconditionFailed, aTimestamp, ErrSuperseded},
+		{"conflict without conditional writes", false, http.StatusConflict, "", ErrWriterRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := new(ContentBodyParserMock)
+			testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+			assert.NoError(t, err, "It should not return an error")
+			p.On("getUUID", aContentBody).Return(aUUID, nil)
+
+			requests := 0
+			nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests++
+				assert.Equal(t, tt.expHeader, req.Header.Get(ifUnmodifiedSinceHeader))
+				w.WriteHeader(tt.status)
+			}))
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
+			assert.NoError(t, err, "It should not return an error by creating a message")
+			msg.AddContentTypeHeader(aContentType)
+
+			w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{RetryPolicy: RetryPolicy{MaxAttempts: 3}, ConditionalWrites: tt.conditionalWrites})
+			_, err = w.WriteToCollection(msg, methodeCollectionName)
+
+			if tt.expErr == nil {
+				assert.NoError(t, err, "It should not return an error")
+			} else {
+				assert.True(t, errors.Is(err, tt.expErr), "It should return %v, got %v", tt.expErr, err)
+			}
+			assert.Equal(t, 1, requests, "It should not retry the request")
+		})
+	}
+}
+
+func TestConditionalWriteKeepsTimestampPrecision(t *testing.T) {
+	tests := []struct {
+		timestamp string
+		expHeader string
+	}{
+		{"2017-02-16T12:56:16.9Z", "2017-02-16T12:56:16.9Z"},
+		{"2017-02-16T12:56:16.123456789Z", "2017-02-16T12:56:16.123456789Z"},
+		{"2017-02-16T13:56:16.800+01:00", "2017-02-16T12:56:16.8Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timestamp, func(t *testing.T) {
+			p := new(ContentBodyParserMock)
+			testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+			assert.NoError(t, err, "It should not return an error")
+			p.On("getUUID", mock.Anything).Return(aUUID, nil)
+
+			nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, tt.expHeader, req.Header.Get(ifUnmodifiedSinceHeader))
+				assert.Empty(t, req.Header.Get("If-Unmodified-Since"), "It should not send a timestamp truncated to the second")
+			}))
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", "{}", tt.timestamp, publishRef, messageTypeContentPublished)
+			assert.NoError(t, err, "It should not return an error by creating a message")
+			msg.AddContentTypeHeader(aContentType)
+
+			w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{ConditionalWrites: true})
+			_, err = w.WriteToCollection(msg, methodeCollectionName)
+			assert.NoError(t, err, "It should not return an error")
+		})
+	}
+}
+
+func TestConditionalWriteWithInvalidTimestamp(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+	p.On("getUUID", mock.Anything).Return(aUUID, nil)
+
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Empty(t, req.Header.Get(ifUnmodifiedSinceHeader), "It should write unconditionally")
+	}))
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("", "{}", "yesterday", publishRef, messageTypeContentPublished)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+	msg.AddContentTypeHeader(aContentType)
+
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{ConditionalWrites: true})
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+	assert.NoError(t, err, "It should not return an error")
+}
+
+func TestDryRunDoesNotCallNativeWriter(t *testing.T) {
+	p := new(ContentBodyParserMock)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	assert.NoError(t, err, "It should not return an error")
+	p.On("getUUID", aContentBody).Return(aUUID, nil)
+
+	requests := 0
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+	}))
+	defer nws.Close()
+
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypePartialContentPublished)
+	assert.NoError(t, err, "It should not return an error by creating a message")
+	msg.AddContentTypeHeader(aContentType)
+
+	dryRunWrites := metrics.DryRunWrites.WithLabelValues(methodeCollectionName, "PATCH")
+	before := testutil.ToFloat64(dryRunWrites)
+
+	w := NewWriter(nws.URL, testCollectionsOriginIdsMap, p, WriterOptions{DryRun: true})
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
+
+	assert.NoError(t, err, "It should not return an error")
+	assert.Equal(t, aUUID, result.UUID)
+	assert.Empty(t, result.UpdatedContent)
+	assert.Equal(t, 0, requests, "It should not call the native writer")
+	assert.Equal(t, before+1, testutil.ToFloat64(dryRunWrites), "It should count the skipped call")
+	p.AssertExpectations(t)
+}
+
+func TestIsDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-ingester-dry-run")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dry := WriterOptions{DryRun: true}
+
+	tests := []struct {
+		name    string
+		address string
+		options WriterOptions
+		shadow  bool
+		expDry  bool
+	}{
+		{"native writer service", "http://localhost:8080", WriterOptions{}, false, false},
+		{"dry native writer service", "http://localhost:8080", dry, false, true},
+		{"dry file store", "file://" + dir, dry, false, true},
+		{"dry memory store", "mem://", dry, false, true},
+		{"shadowed dry writer", "mem://", dry, true, true},
+		{"shadowed writer", "mem://", WriterOptions{}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWriter(tt.address, &config.Configuration{}, new(ContentBodyParserMock), tt.options)
+			if tt.shadow {
+				shadowWriter := NewShadowWriter(w, NewWriter("mem://", &config.Configuration{}, new(ContentBodyParserMock), WriterOptions{}), 1)
+				defer shadowWriter.Stop()
+				w = shadowWriter
+			}
+			assert.Equal(t, tt.expDry, IsDryRun(w))
+		})
+	}
+	assert.False(t, IsDryRun(writing(WriteResult{}, nil)), "A writer that cannot run dry should not be dry")
+}
+
+func TestWriteUsesUUIDFieldsOfRoute(t *testing.T) {
+	conf, err := getConfig(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "collection": "methode"}
+		],
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_fields": ["post.id", "id"]}
+		]
+	}`)
+	require.NoError(t, err)
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		originSystemID string
+		collection     string
+		expUUID        string
+	}{
+		{"route with UUID fields", "http://cmdb.ft.com/systems/wordpress", "wordpress", "9e5c0b56-ab7c-4a2c-9f60-1f7a2c3e8a55"},
+		{"route without UUID fields", methodeOriginSystemID, "methode", aUUID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, "/"+tt.collection+"/"+tt.expUUID, req.URL.Path)
+			}))
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", `{"uuid": "`+aUUID+`", "post": {"id": "9e5c0b56-ab7c-4a2c-9f60-1f7a2c3e8a55"}}`, aTimestamp, publishRef, messageTypeContentPublished)
+			require.NoError(t, err)
+			msg.AddContentTypeHeader(aContentType)
+			msg.AddOriginSystemIDHeader(tt.originSystemID)
+
+			w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+			result, err := w.WriteToCollection(msg, tt.collection)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expUUID, result.UUID)
+		})
+	}
+}
+
+func TestWriteDerivesMissingUUID(t *testing.T) {
+	conf, err := getConfig(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_derivation": {"version": 3, "namespace": "url", "field": "webUrl"}}
+		]
+	}`)
+	require.NoError(t, err)
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		expUUID    string
+		expDerived bool
+	}{
+		{"content with UUID", `{"uuid": "` + aUUID + `", "webUrl": "https://www.ft.com/content/12345"}`, aUUID, false},
+		{"content without UUID", `{"webUrl": "https://www.ft.com/content/12345"}`, "eed1fc2d-9522-3c49-b135-ee546764432f", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, "/wordpress/"+tt.expUUID, req.URL.Path)
+			}))
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", tt.body, aTimestamp, publishRef, messageTypeContentPublished)
+			require.NoError(t, err)
+			msg.AddContentTypeHeader(aContentType)
+			msg.AddOriginSystemIDHeader("http://cmdb.ft.com/systems/wordpress")
+
+			w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+			result, err := w.WriteToCollection(msg, "wordpress")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expUUID, result.UUID)
+			assert.Equal(t, tt.expDerived, result.DerivedUUID)
+		})
+	}
+}
+
+func TestWriteFailsWhenUUIDCannotBeDerived(t *testing.T) {
+	conf, err := getConfig(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_derivation": {"version": 3, "namespace": "url", "field": "webUrl"}}
+		]
+	}`)
+	require.NoError(t, err)
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+
+	msg, err := NewNativeMessage("", `{"id": 12345}`, aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+	msg.AddContentTypeHeader(aContentType)
+	msg.AddOriginSystemIDHeader("http://cmdb.ft.com/systems/wordpress")
+
+	w := NewWriter("http://an-address.com", conf, parser, WriterOptions{})
+	_, err = w.WriteToCollection(msg, "wordpress")
+
+	assert.True(t, errors.Is(err, ErrUUIDNotFound), "It should return a UUID not found error")
+}
+
+func TestWriteRestrictsUUIDVersionsOfRoute(t *testing.T) {
+	conf, err := getConfig(`{
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_versions": [3, 5]}
+		]
+	}`)
+	require.NoError(t, err)
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		uuid   string
+		expErr error
+	}{
+		{"accepted version", "EED1FC2D-9522-3C49-B135-EE546764432F", nil},
+		{"rejected version", aUUID, ErrInvalidUUID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, "/wordpress/eed1fc2d-9522-3c49-b135-ee546764432f", req.URL.Path, "It should write under the normalised UUID")
+			}))
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", `{"uuid": "`+tt.uuid+`"}`, aTimestamp, publishRef, messageTypeContentPublished)
+			require.NoError(t, err)
+			msg.AddContentTypeHeader(aContentType)
+			msg.AddOriginSystemIDHeader("http://cmdb.ft.com/systems/wordpress")
+
+			w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+			_, err = w.WriteToCollection(msg, "wordpress")
+
+			if tt.expErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tt.expErr), "It should return %v, got %v", tt.expErr, err)
+			}
+		})
+	}
+}
+
+func TestWriteRestrictsVersionOfDerivedUUID(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		versions string
+		expErr   error
+	}{
+		{"accepted version", "[3]", nil},
+		{"rejected version", "[4]", ErrInvalidUUID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := getConfig(`{
+				"http://cmdb.ft.com/systems/wordpress": [
+					{"content_type": ".*", "collection": "wordpress", "uuid_versions": ` + tt.versions + `,
+					 "uuid_derivation": {"version": 3, "namespace": "url", "field": "webUrl"}}
+				]
+			}`)
+			require.NoError(t, err)
+
+			msg, err := NewNativeMessage("", `{"webUrl": "https://www.ft.com/content/12345"}`, aTimestamp, publishRef, messageTypeContentPublished)
+			require.NoError(t, err)
+			msg.AddContentTypeHeader(aContentType)
+			msg.AddOriginSystemIDHeader("http://cmdb.ft.com/systems/wordpress")
+
+			w := NewMemoryWriter(conf, parser, WriterOptions{})
+			result, err := w.WriteToCollection(msg, "wordpress")
+
+			if tt.expErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, "eed1fc2d-9522-3c49-b135-ee546764432f", result.UUID)
+				assert.True(t, result.DerivedUUID)
+			} else {
+				assert.True(t, errors.Is(err, tt.expErr), "It should return %v, got %v", tt.expErr, err)
+				_, found := w.Content("wordpress", "eed1fc2d-9522-3c49-b135-ee546764432f")
+				assert.False(t, found, "It should not write content under a UUID of a rejected version")
+			}
+		})
+	}
+}
+
+func TestContentUUIDHeaderIsNormalised(t *testing.T) {
+	msg, err := NewNativeMessage("", "", aTimestamp, publishRef, messageTypeContentDeleted)
+	require.NoError(t, err)
+
+	msg.AddContentUUIDHeader("urn:uuid:572D0ACC-3F12-4E70-8830-8092C1042A52")
+	contentUUID, err := ContentUUID(msg, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, aUUID, contentUUID)
+
+	msg.AddContentUUIDHeader("00000000-0000-0000-0000-000000000000")
+	_, err = ContentUUID(msg, nil)
+	assert.True(t, errors.Is(err, ErrInvalidUUID), "It should reject the nil UUID")
+}
+
+func TestWritePreservesOriginalBody(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	parser := new(ContentBodyParserMock)
+	parser.On("getUUID", mock.Anything).Return(aUUID, nil)
+	conf, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			body, err := ioutil.ReadFile(input)
+			require.NoError(t, err)
+			expected, err := ioutil.ReadFile(strings.TrimSuffix(input, ".json") + ".golden")
+			require.NoError(t, err)
+
+			var written []byte
+			nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				written, _ = ioutil.ReadAll(req.Body)
+			}))
+			defer nws.Close()
+
+			msg, err := NewNativeMessage("", string(body), aTimestamp, publishRef, messageTypeContentPublished)
+			require.NoError(t, err)
+
+			w := NewWriter(nws.URL, conf, parser, WriterOptions{})
+			_, err = w.WriteToCollection(msg, methodeCollectionName)
+			require.NoError(t, err)
+
+			assert.Equal(t, string(expected), string(written), "The written body should only differ from the original by the injected fields")
+		})
+	}
+}
+
+func TestBuildNativeMessageKeepsNumberPrecision(t *testing.T) {
+	msg, err := NewNativeMessage("", `{"id": 12345678901234567890}`, aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+	assert.Equal(t, json.Number("12345678901234567890"), msg.body["id"])
+}
+
+func TestBuildNativeMessageFailsWithTrailingData(t *testing.T) {
+	_, err := NewNativeMessage("", `{"foo": "bar"} {}`, aTimestamp, publishRef, messageTypeContentPublished)
+	assert.True(t, errors.Is(err, ErrInvalidBody), "It should return an invalid body error")
+}
+
+func TestGetCollectionOfSkippedRoute(t *testing.T) {
+	conf, err := getConfig(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "skip": true}
+		]
+	}`)
+	require.NoError(t, err)
+
+	w := NewWriter("http://localhost:0", conf, nil, WriterOptions{})
+	_, err = w.GetCollection(methodeOriginSystemID, aContentType)
+	assert.True(t, errors.Is(err, ErrRouteSkipped), "It should return a skipped route error")
+	assert.False(t, errors.Is(err, ErrRouteNotConfigured), "A skipped route is configured")
+}
@@ -0,0 +1,177 @@
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// rawField is a member set in the top-level object of a raw JSON body
+type rawField struct {
+	name  string
+	value string
+}
+
+// memberSpan is the position of a member of a JSON object, from the opening quote of its name to the end of its value
+type memberSpan struct {
+	name                            string
+	start, nameEnd, valueStart, end int
+}
+
+// injectFields returns the JSON object with the fields set, leaving every other byte of it untouched.
+// The value of a field already in the object is replaced in place, while a missing field is appended
+// as the last member, laid out like the first member of the object.
+func injectFields(raw []byte, fields []rawField) ([]byte, error) {
+	open, members, err := scanObject(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte, len(fields))
+	for _, f := range fields {
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		values[f.name] = value
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(raw) + 64*len(fields))
+	found := make(map[string]bool, len(fields))
+	last := 0
+	for _, m := range members {
+		value, ok := values[m.name]
+		if !ok {
+			continue
+		}
+		out.Write(raw[last:m.valueStart])
+		out.Write(value)
+		last = m.end
+		found[m.name] = true
+	}
+
+	// the missing fields go after the last member, before any whitespace preceding the closing brace
+	insertAt := open + 1
+	indent, colon := "", ":"
+	if len(members) > 0 {
+		first := members[0]
+		insertAt = members[len(members)-1].end
+		indent = string(raw[open+1 : first.start])
+		colon = string(raw[first.nameEnd:first.valueStart])
+	}
+	out.Write(raw[last:insertAt])
+	separator := len(members) > 0
+	for _, f := range fields {
+		if found[f.name] {
+			continue
+		}
+		if separator {
+			out.WriteByte(',')
+		}
+		separator = true
+		name, _ := json.Marshal(f.name)
+		out.WriteString(indent)
+		out.Write(name)
+		out.WriteString(colon)
+		out.Write(values[f.name])
+	}
+	out.Write(raw[insertAt:])
+	return out.Bytes(), nil
+}
+
+// scanObject returns the position of the opening brace of a top-level JSON object and the positions of its members
+func scanObject(raw []byte) (int, []memberSpan, error) {
+	pos := skipSpaces(raw, 0)
+	if pos >= len(raw) || raw[pos] != '{' {
+		return 0, nil, fmt.Errorf("JSON body is not an object")
+	}
+	open := pos
+	pos = skipSpaces(raw, pos+1)
+
+	var members []memberSpan
+	for pos < len(raw) && raw[pos] != '}' {
+		if len(members) > 0 {
+			if raw[pos] != ',' {
+				return 0, nil, fmt.Errorf("expected ',' at offset %v of the JSON body", pos)
+			}
+			pos = skipSpaces(raw, pos+1)
+		}
+		if pos >= len(raw) || raw[pos] != '"' {
+			return 0, nil, fmt.Errorf("expected a member name at offset %v of the JSON body", pos)
+		}
+		start := pos
+		nameEnd := skipString(raw, pos)
+		var name string
+		if err := json.Unmarshal(raw[start:nameEnd], &name); err != nil {
+			return 0, nil, err
+		}
+		pos = skipSpaces(raw, nameEnd)
+		if pos >= len(raw) || raw[pos] != ':' {
+			return 0, nil, fmt.Errorf("expected ':' at offset %v of the JSON body", pos)
+		}
+		valueStart := skipSpaces(raw, pos+1)
+		end := skipValue(raw, valueStart)
+		members = append(members, memberSpan{name: name, start: start, nameEnd: nameEnd, valueStart: valueStart, end: end})
+		pos = skipSpaces(raw, end)
+	}
+	if pos >= len(raw) {
+		return 0, nil, fmt.Errorf("unterminated JSON object")
+	}
+	return open, members, nil
+}
+
+func skipSpaces(raw []byte, pos int) int {
+	for pos < len(raw) && (raw[pos] == ' ' || raw[pos] == '\t' || raw[pos] == '\n' || raw[pos] == '\r') {
+		pos++
+	}
+	return pos
+}
+
+// skipString returns the position after the string starting at the opening quote at pos
+func skipString(raw []byte, pos int) int {
+	for pos++; pos < len(raw); pos++ {
+		switch raw[pos] {
+		case '\\':
+			pos++
+		case '"':
+			return pos + 1
+		}
+	}
+	return pos
+}
+
+// skipValue returns the position after the JSON value starting at pos, which is expected to be valid JSON
+func skipValue(raw []byte, pos int) int {
+	depth := 0
+	for pos < len(raw) {
+		switch raw[pos] {
+		case '"':
+			pos = skipString(raw, pos)
+			if depth == 0 {
+				return pos
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return pos
+			}
+			depth--
+			if depth == 0 {
+				return pos + 1
+			}
+		case ',':
+			if depth == 0 {
+				return pos
+			}
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return pos
+			}
+		}
+		pos++
+	}
+	return pos
+}
@@ -0,0 +1,97 @@
+package native
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines how many times and how often a request to the native writer is retried
+// when it fails because of a network error or a retryable HTTP status code.
+// The zero value performs a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on each following retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, including delays requested by Retry-After
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomised
+	Jitter float64
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the delay to wait after the given failed attempt, counting from 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < math.MaxInt64/2 && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	delay = p.capped(delay)
+
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	if jitter > 0 {
+		delay -= time.Duration(jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// delay returns the delay to wait after the given failed attempt, honouring the Retry-After header of the response, if any
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return p.capped(retryAfter)
+		}
+	}
+	return p.backoff(attempt)
+}
+
+func (p RetryPolicy) capped(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
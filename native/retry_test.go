@@ -0,0 +1,187 @@
+package native
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    10 * time.Millisecond,
+	Jitter:      0.5,
+}
+
+func setupFlakyNativeWriterService(t *testing.T, statuses []int, headers map[string]string) (*httptest.Server, *int32) {
+	var calls int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		assert.Equal(t, publishRef, req.Header.Get(transactionIDHeader))
+		assert.Equal(t, "/"+methodeCollectionName+"/"+aUUID, req.URL.Path)
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		status := statuses[len(statuses)-1]
+		if int(call) <= len(statuses) {
+			status = statuses[call-1]
+		}
+		w.WriteHeader(status)
+	})), &calls
+}
+
+// writeWithOptions writes a message with a new writer calling the native writer at the address with the given options
+func writeWithOptions(t *testing.T, address string, options WriterOptions) error {
+	p := new(ContentBodyParserMock)
+	p.On("getUUID", aContentBody).Return(aUUID, nil)
+	testCollectionsOriginIdsMap, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+
+	msg, err := NewNativeMessage("", "{}", aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+	msg.AddContentTypeHeader(aContentType)
+
+	w := NewWriter(address, testCollectionsOriginIdsMap, p, options)
+	_, err = w.WriteToCollection(msg, methodeCollectionName)
+	return err
+}
+
+func TestWriteRetriesRetryableStatusCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		expCalls int32
+	}{
+		{"500 then success", []int{500, 200}, false, 2},
+		{"502 then success", []int{502, 200}, false, 2},
+		{"503 then 504 then success", []int{503, 504, 200}, false, 3},
+		{"429 then success", []int{429, 200}, false, 2},
+		{"always unavailable", []int{503}, true, 3},
+		{"bad request", []int{400}, true, 1},
+		{"unprocessable entity", []int{422}, true, 1},
+		{"not implemented", []int{501}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nws, calls := setupFlakyNativeWriterService(t, tt.statuses, nil)
+			defer nws.Close()
+
+			err := writeWithOptions(t, nws.URL, WriterOptions{RetryPolicy: testRetryPolicy})
+
+			assert.Equal(t, tt.wantErr, err != nil, "Unexpected error: %v", err)
+			assert.Equal(t, tt.expCalls, atomic.LoadInt32(calls), "Unexpected number of attempts")
+		})
+	}
+}
+
+func TestWriteWithoutRetryPolicyAttemptsOnce(t *testing.T) {
+	nws, calls := setupFlakyNativeWriterService(t, []int{503, 200}, nil)
+	defer nws.Close()
+
+	err := writeWithOptions(t, nws.URL, WriterOptions{})
+
+	assert.Error(t, err, "It should return an error")
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestWriteRetriesNetworkErrors(t *testing.T) {
+	var calls int32
+	nws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.NoError(t, err)
+			conn.Close()
+			return
+		}
+	}))
+	defer nws.Close()
+
+	err := writeWithOptions(t, nws.URL, WriterOptions{RetryPolicy: testRetryPolicy})
+
+	assert.NoError(t, err, "It should not return an error")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestWriteFailsWhenNativeWriterIsUnreachable(t *testing.T) {
+	nws, calls := setupFlakyNativeWriterService(t, []int{200}, nil)
+	address := nws.URL
+	nws.Close()
+
+	err := writeWithOptions(t, address, WriterOptions{RetryPolicy: testRetryPolicy})
+
+	assert.Error(t, err, "It should return an error")
+	assert.Equal(t, int32(0), atomic.LoadInt32(calls))
+}
+
+func TestWriteRetryHonoursRetryAfter(t *testing.T) {
+	nws, calls := setupFlakyNativeWriterService(t, []int{429, 200}, map[string]string{"Retry-After": "1"})
+	defer nws.Close()
+
+	policy := testRetryPolicy
+	policy.MaxDelay = 2 * time.Second
+	start := time.Now()
+	err := writeWithOptions(t, nws.URL, WriterOptions{RetryPolicy: policy})
+
+	assert.NoError(t, err, "It should not return an error")
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	assert.True(t, time.Since(start) >= time.Second, "It should wait for the time requested by Retry-After")
+}
+
+func TestWriteRetryCapsRetryAfterToMaxDelay(t *testing.T) {
+	nws, calls := setupFlakyNativeWriterService(t, []int{503, 200}, map[string]string{"Retry-After": "3600"})
+	defer nws.Close()
+
+	start := time.Now()
+	err := writeWithOptions(t, nws.URL, WriterOptions{RetryPolicy: testRetryPolicy})
+
+	assert.NoError(t, err, "It should not return an error")
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	assert.True(t, time.Since(start) < time.Second, "It should not wait longer than the max delay")
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 800*time.Millisecond, p.backoff(4))
+	assert.Equal(t, time.Second, p.backoff(5))
+	assert.Equal(t, time.Second, p.backoff(100))
+}
+
+func TestRetryPolicyBackoffWithJitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		d := p.backoff(2)
+		assert.True(t, d > 100*time.Millisecond && d <= 200*time.Millisecond, "Unexpected delay %v", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package native
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/native-ingester/metrics"
+)
+
+// Results of the comparison of a shadow write with its primary write
+const (
+	shadowMatch           = "match"
+	shadowStatusMismatch  = "status-mismatch"
+	shadowContentMismatch = "content-mismatch"
+	shadowDropped         = "dropped"
+)
+
+// ShadowWriter is a Writer whose writes are done by a primary writer, which alone decides their outcome,
+// then repeated asynchronously by a shadow writer, for instance while migrating the native store.
+// The outcomes of both writes, and the content returned for partial content, are compared and their discrepancies
+// are logged and counted. The shadow writes are done one by one in the order of the primary writes;
+// when more than the queue depth are pending, the new ones are dropped rather than slowing down the primary writes.
+type ShadowWriter struct {
+	Writer
+	shadow Writer
+	queue  chan shadowWrite
+	done   chan struct{}
+
+	mutex   sync.RWMutex
+	stopped bool
+}
+
+// shadowWrite is a write done by the primary writer, to be repeated by the shadow writer
+type shadowWrite struct {
+	msg        NativeMessage
+	collection string
+	result     WriteResult
+	err        error
+}
+
+// NewShadowWriter returns a ShadowWriter repeating the writes of the primary writer with the shadow writer,
+// with at most queueDepth shadow writes pending
+func NewShadowWriter(primary Writer, shadow Writer, queueDepth int) *ShadowWriter {
+	w := &ShadowWriter{
+		Writer: primary,
+		shadow: shadow,
+		queue:  make(chan shadowWrite, queueDepth),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *ShadowWriter) WriteToCollection(msg NativeMessage, collection string) (WriteResult, error) {
+	result, err := w.Writer.WriteToCollection(msg, collection)
+	// a message whose UUID cannot be resolved is not written, so there is nothing to compare
+	if result.UUID != "" {
+		w.enqueue(shadowWrite{msg, collection, result, err})
+	}
+	return result, err
+}
+
+// isDryRun returns true if the primary writer skips its writes, as they alone decide the outcome
+func (w *ShadowWriter) isDryRun() bool {
+	return IsDryRun(w.Writer)
+}
+
+func (w *ShadowWriter) enqueue(write shadowWrite) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.stopped {
+		return
+	}
+	select {
+	case w.queue <- write:
+	default:
+		metrics.ShadowWrites.WithLabelValues(write.collection, shadowDropped).Inc()
+		logger.NewEntry(write.msg.TransactionID()).
+			WithUUID(write.result.UUID).
+			WithField("collection", write.collection).
+			Warn("Shadow native writer is lagging behind, skipping shadow write")
+	}
+}
+
+// Stop waits for the pending shadow writes, then stops the shadow writer.
+// The writes that follow are only done by the primary writer.
+func (w *ShadowWriter) Stop() {
+	w.mutex.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.queue)
+	}
+	w.mutex.Unlock()
+	<-w.done
+}
+
+func (w *ShadowWriter) run() {
+	defer close(w.done)
+	for write := range w.queue {
+		w.write(write)
+	}
+}
+
+func (w *ShadowWriter) write(write shadowWrite) {
+	entry := logger.NewEntry(write.msg.TransactionID()).
+		WithUUID(write.result.UUID).
+		WithField("collection", write.collection).
+		WithField("method", writeMethod(write.msg))
+	defer func() {
+		if r := recover(); r != nil {
+			entry.WithField("panic", r).Error("Shadow native writer panicked, ignoring shadow write")
+		}
+	}()
+
+	result, err := w.shadow.WriteToCollection(write.msg, write.collection)
+	primaryStatus, shadowStatus := writeStatus(write.err), writeStatus(err)
+	entry = entry.WithField("primaryStatus", primaryStatus).WithField("shadowStatus", shadowStatus)
+	switch {
+	case primaryStatus != shadowStatus:
+		metrics.ShadowWrites.WithLabelValues(write.collection, shadowStatusMismatch).Inc()
+		if err != nil {
+			entry = entry.WithError(err)
+		}
+		entry.Warn("Shadow native writer returned another status than the primary one")
+	case write.msg.IsPartialContent() && write.err == nil && !sameContent(write.result.UpdatedContent, result.UpdatedContent):
+		metrics.ShadowWrites.WithLabelValues(write.collection, shadowContentMismatch).Inc()
+		entry.Warn("Shadow native writer returned other content than the primary one for partial content")
+	default:
+		metrics.ShadowWrites.WithLabelValues(write.collection, shadowMatch).Inc()
+	}
+}
+
+// writeStatus describes the outcome of a write: the status code of the native writer that rejected it,
+// or else the kind of failure. Successful writes are alike whatever the store, whether the native writer
+// created, updated or did not find the content.
+func writeStatus(err error) string {
+	var rejectedErr *WriterRejectedError
+	switch {
+	case errors.As(err, &rejectedErr):
+		return strconv.Itoa(rejectedErr.StatusCode)
+	case errors.Is(err, ErrSuperseded):
+		return "superseded"
+	case errors.Is(err, ErrWriterUnavailable):
+		return "unavailable"
+	case err != nil:
+		return "failed"
+	}
+	return "ok"
+}
+
+// sameContent compares two JSON objects regardless of the order and the formatting of their members,
+// or else compares them as they are
+func sameContent(primary string, shadow string) bool {
+	if primary == shadow {
+		return true
+	}
+	primaryObject, err := decodeJSONObject([]byte(primary))
+	if err != nil {
+		return false
+	}
+	shadowObject, err := decodeJSONObject([]byte(shadow))
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(primaryObject, shadowObject)
+}
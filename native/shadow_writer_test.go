@@ -0,0 +1,217 @@
+package native
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/native-ingester/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubWriter writes with the given function
+type stubWriter struct {
+	write func(msg NativeMessage, collection string) (WriteResult, error)
+}
+
+func (w stubWriter) GetCollection(originID string, contentType string) (string, error) {
+	return methodeCollectionName, nil
+}
+
+func (w stubWriter) WriteToCollection(msg NativeMessage, collection string) (WriteResult, error) {
+	return w.write(msg, collection)
+}
+
+func (w stubWriter) ConnectivityCheck() (string, error) {
+	return "", nil
+}
+
+func writing(result WriteResult, err error) stubWriter {
+	return stubWriter{func(NativeMessage, string) (WriteResult, error) {
+		return result, err
+	}}
+}
+
+func shadowWrites(collection string, result string) float64 {
+	return testutil.ToFloat64(metrics.ShadowWrites.WithLabelValues(collection, result))
+}
+
+func writeWithShadow(t *testing.T, primary Writer, shadow Writer, messageType string, collection string) (WriteResult, error) {
+	w := NewShadowWriter(primary, shadow, 10)
+	msg, err := NewNativeMessage("", `{"uuid":"`+aUUID+`"}`, aTimestamp, publishRef, messageType)
+	require.NoError(t, err)
+	result, err := w.WriteToCollection(msg, collection)
+	w.Stop()
+	return result, err
+}
+
+func TestShadowWriterComparesStatuses(t *testing.T) {
+	rejected := &WriterRejectedError{StatusCode: http.StatusBadRequest}
+	unavailable := classify(ErrWriterUnavailable, errors.New("connection refused"))
+	tests := []struct {
+		name          string
+		primaryResult WriteResult
+		primaryErr    error
+		shadowResult  WriteResult
+		shadowErr     error
+		expResult     string
+	}{
+		{"both successful", WriteResult{UUID: aUUID}, nil, WriteResult{UUID: aUUID}, nil, shadowMatch},
+		{"same failure", WriteResult{UUID: aUUID}, rejected, WriteResult{UUID: aUUID}, rejected, shadowMatch},
+		{"shadow rejection", WriteResult{UUID: aUUID}, nil, WriteResult{UUID: aUUID}, rejected, shadowStatusMismatch},
+		{"shadow failure", WriteResult{UUID: aUUID}, nil, WriteResult{UUID: aUUID}, unavailable, shadowStatusMismatch},
+		{"other rejection", WriteResult{UUID: aUUID}, rejected, WriteResult{UUID: aUUID}, &WriterRejectedError{StatusCode: 422}, shadowStatusMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := "shadow-status-" + tt.name
+			before := shadowWrites(collection, tt.expResult)
+			result, err := writeWithShadow(t, writing(tt.primaryResult, tt.primaryErr), writing(tt.shadowResult, tt.shadowErr), messageTypeContentPublished, collection)
+
+			assert.Equal(t, tt.primaryResult, result, "The primary result should be returned")
+			assert.Equal(t, tt.primaryErr, err, "The primary error should be returned")
+			assert.Equal(t, before+1, shadowWrites(collection, tt.expResult))
+		})
+	}
+}
+
+func TestShadowWriterComparesPartialContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		primary     string
+		shadow      string
+		messageType string
+		expResult   string
+	}{
+		{"same content", `{"a":1,"b":{"c":2}}`, `{"b": {"c": 2}, "a": 1}`, messageTypePartialContentPublished, shadowMatch},
+		{"other content", `{"a":1}`, `{"a":2}`, messageTypePartialContentPublished, shadowContentMismatch},
+		{"other content of a full write", `{"a":1}`, `{"a":2}`, messageTypeContentPublished, shadowMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := "shadow-content-" + tt.name
+			primary := writing(WriteResult{UUID: aUUID, UpdatedContent: tt.primary}, nil)
+			shadow := writing(WriteResult{UUID: aUUID, UpdatedContent: tt.shadow}, nil)
+			before := shadowWrites(collection, tt.expResult)
+			_, err := writeWithShadow(t, primary, shadow, tt.messageType, collection)
+
+			assert.NoError(t, err)
+			assert.Equal(t, before+1, shadowWrites(collection, tt.expResult))
+		})
+	}
+}
+
+func TestShadowWriterDoesNotWaitForShadow(t *testing.T) {
+	const collection = "shadow-slow"
+	release := make(chan struct{})
+	slow := stubWriter{func(NativeMessage, string) (WriteResult, error) {
+		<-release
+		return WriteResult{UUID: aUUID}, nil
+	}}
+	droppedBefore, matchedBefore := shadowWrites(collection, shadowDropped), shadowWrites(collection, shadowMatch)
+	w := NewShadowWriter(writing(WriteResult{UUID: aUUID}, nil), slow, 1)
+	msg, err := NewNativeMessage("", `{"uuid":"`+aUUID+`"}`, aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := w.WriteToCollection(msg, collection)
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) < time.Second, "The primary writes should not wait for the shadow writer")
+
+	close(release)
+	w.Stop()
+	dropped := shadowWrites(collection, shadowDropped) - droppedBefore
+	matched := shadowWrites(collection, shadowMatch) - matchedBefore
+	assert.True(t, dropped >= 3, "The writes beyond the queue depth should be dropped, %v were", dropped)
+	assert.Equal(t, float64(5), dropped+matched, "Every write should be either shadowed or dropped")
+}
+
+func TestShadowWriterSurvivesShadowPanic(t *testing.T) {
+	const collection = "shadow-panic"
+	panicking := stubWriter{func(NativeMessage, string) (WriteResult, error) {
+		panic("shadow writer bug")
+	}}
+	w := NewShadowWriter(writing(WriteResult{UUID: aUUID}, nil), panicking, 10)
+	msg, err := NewNativeMessage("", `{"uuid":"`+aUUID+`"}`, aTimestamp, publishRef, messageTypeContentPublished)
+	require.NoError(t, err)
+
+	_, err = w.WriteToCollection(msg, collection)
+	assert.NoError(t, err)
+	_, err = w.WriteToCollection(msg, collection)
+	assert.NoError(t, err)
+	w.Stop()
+
+	_, err = w.WriteToCollection(msg, collection)
+	assert.NoError(t, err, "The primary writes should go on once the shadow writer is stopped")
+}
+
+func TestShadowWriterSkipsUnresolvedMessages(t *testing.T) {
+	const collection = "shadow-unresolved"
+	called := false
+	shadow := stubWriter{func(NativeMessage, string) (WriteResult, error) {
+		called = true
+		return WriteResult{}, nil
+	}}
+	_, err := writeWithShadow(t, writing(WriteResult{}, classify(ErrUUIDNotFound, errors.New("no uuid"))), shadow, messageTypeContentPublished, collection)
+
+	assert.True(t, errors.Is(err, ErrUUIDNotFound))
+	assert.False(t, called, "A message whose UUID is not resolved should not be shadowed")
+}
+
+func TestShadowWriterWithMemoryWriters(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	collections, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+	primary := NewMemoryWriter(collections, parser, WriterOptions{})
+	shadow := NewMemoryWriter(collections, parser, WriterOptions{})
+	before := shadowWrites("shadow-memory", shadowMatch)
+
+	_, err = writeWithShadow(t, primary, shadow, messageTypeContentPublished, "shadow-memory")
+	require.NoError(t, err)
+
+	primaryContent, _ := primary.Content("shadow-memory", aUUID)
+	shadowContent, found := shadow.Content("shadow-memory", aUUID)
+	assert.True(t, found, "The content should be written by the shadow writer")
+	assert.Equal(t, primaryContent, shadowContent)
+	assert.Equal(t, before+1, shadowWrites("shadow-memory", shadowMatch))
+}
+
+func TestShadowWriterMatchesHTTPPrimaryWithMemoryShadow(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		messageType string
+	}{
+		{"write", http.StatusOK, messageTypeContentPublished},
+		{"write of new content", http.StatusCreated, messageTypeContentPublished},
+		{"delete of missing content", http.StatusNotFound, messageTypeContentDeleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nws, calls := setupFlakyNativeWriterService(t, []int{tt.status}, nil)
+			defer nws.Close()
+			parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+			require.NoError(t, err)
+			collections, err := getConfig(strCollectionsOriginIdsMap)
+			require.NoError(t, err)
+			primary := NewWriter(nws.URL, collections, parser, WriterOptions{})
+			shadow := NewWriter("mem://", collections, parser, WriterOptions{})
+			matchedBefore := shadowWrites(methodeCollectionName, shadowMatch)
+			mismatchedBefore := shadowWrites(methodeCollectionName, shadowStatusMismatch)
+
+			_, err = writeWithShadow(t, primary, shadow, tt.messageType, methodeCollectionName)
+
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), atomic.LoadInt32(calls), "The primary native writer should be called")
+			assert.Equal(t, matchedBefore+1, shadowWrites(methodeCollectionName, shadowMatch))
+			assert.Equal(t, mismatchedBefore, shadowWrites(methodeCollectionName, shadowStatusMismatch))
+		})
+	}
+}
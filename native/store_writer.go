@@ -0,0 +1,229 @@
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/metrics"
+)
+
+const (
+	fileScheme   = "file://"
+	memoryScheme = "mem://"
+)
+
+// documentExtensions are the extensions of the documents of a local store, by format of the native content
+var documentExtensions = []string{".json", ".xml", ".bin"}
+
+// document is native content held by a local store
+type document struct {
+	body         []byte
+	lastModified time.Time
+}
+
+// documentStore keeps native content by collection and document name, in place of the native writer service
+type documentStore interface {
+	// read returns the document, and whether it is in the store
+	read(collection string, name string) (document, bool, error)
+	// write replaces the document atomically, so that it is never read half written
+	write(collection string, name string, doc document) error
+	// remove deletes the document, returning whether it was in the store
+	remove(collection string, name string) (bool, error)
+	// check returns an error if documents cannot be written
+	check() error
+}
+
+// storeWriter is a Writer writing native content in a local documentStore rather than calling the native writer service.
+// Each content is the document {uuid}.json, .xml or .bin in its collection, according to the format of its body.
+// Partial content is merged in the JSON document as a JSON merge patch (RFC 7396).
+type storeWriter struct {
+	contentRouter
+	store   documentStore
+	options WriterOptions
+	// mutex makes the merge of partial content with the content in the store atomic
+	mutex sync.Mutex
+}
+
+func newStoreWriter(collections config.Provider, parser ContentBodyParser, store documentStore, options WriterOptions) *storeWriter {
+	return &storeWriter{contentRouter: contentRouter{collections, parser}, store: store, options: options}
+}
+
+func (w *storeWriter) WriteToCollection(msg NativeMessage, collection string) (WriteResult, error) {
+	contentUUID, derived, err := w.resolveUUID(msg)
+	if err != nil {
+		logger.NewEntry(msg.TransactionID()).WithError(err).Error("Error extracting uuid. Ignoring message.")
+		return WriteResult{}, err
+	}
+	result := WriteResult{UUID: contentUUID, DerivedUUID: derived}
+	if derived {
+		logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Derived the UUID of content without UUID")
+	}
+	logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Start processing native publish event")
+
+	method := writeMethod(msg)
+	if w.options.DryRun {
+		metrics.DryRunWrites.WithLabelValues(collection, method).Inc()
+		logger.NewEntry(msg.TransactionID()).
+			WithUUID(contentUUID).
+			WithField("collection", collection).
+			WithField("method", method).
+			Info("Dry run: skipping write to native store")
+		return result, nil
+	}
+
+	start := time.Now()
+	updated, err := w.write(msg, method, collection, contentUUID)
+	metrics.NativeWriterRequestDuration.WithLabelValues(collection, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		entry := logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).WithError(err)
+		if errors.Is(err, ErrSuperseded) {
+			entry.WithField("lastModified", msg.timestamp).Info("Native store holds a newer version of the content. Skipping message.")
+		} else {
+			entry.Error("Error writing to native store. Ignoring message.")
+		}
+		return result, err
+	}
+	result.UpdatedContent = string(updated)
+	logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Successfully finished processing native publish event")
+	return result, nil
+}
+
+// write applies the message to the store, returning the content held by the store afterwards
+func (w *storeWriter) write(msg NativeMessage, method string, collection string, contentUUID string) ([]byte, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if method == "DELETE" {
+		found := false
+		for _, ext := range documentExtensions {
+			removed, err := w.store.remove(collection, contentUUID+ext)
+			if err != nil {
+				return nil, classify(ErrWriterUnavailable, err)
+			}
+			found = found || removed
+		}
+		if !found {
+			logger.NewEntry(msg.TransactionID()).WithUUID(contentUUID).Info("Native content was not found, nothing to delete")
+		}
+		return nil, nil
+	}
+
+	name := contentUUID + documentExtension(msg)
+	doc := document{body: msg.rawBody, lastModified: time.Now()}
+	if lastModified, err := time.Parse(time.RFC3339Nano, msg.timestamp); err == nil {
+		doc.lastModified = lastModified
+	}
+	if method == "PATCH" && msg.body == nil {
+		return nil, NewInvalidBodyError("partial content must be a JSON object")
+	}
+
+	if method == "PATCH" || w.options.ConditionalWrites {
+		existing, found, err := w.store.read(collection, name)
+		if err != nil {
+			return nil, classify(ErrWriterUnavailable, err)
+		}
+		if found && w.options.ConditionalWrites && existing.lastModified.After(doc.lastModified) {
+			return nil, classify(ErrSuperseded, fmt.Errorf("native store holds content last modified at %v", existing.lastModified.Format(time.RFC3339Nano)))
+		}
+		if method == "PATCH" {
+			target := []byte("{}")
+			if found {
+				target = existing.body
+			}
+			merged, err := mergeJSON(target, doc.body)
+			if err != nil {
+				return nil, classify(ErrWriterRejected, err)
+			}
+			doc.body = merged
+		}
+	}
+
+	if err := w.store.write(collection, name, doc); err != nil {
+		return nil, classify(ErrWriterUnavailable, err)
+	}
+	return doc.body, nil
+}
+
+// documentExtension returns the extension of the document holding the content of the message
+func documentExtension(msg NativeMessage) string {
+	switch {
+	case msg.body != nil:
+		return ".json"
+	case msg.xmlBody != nil:
+		return ".xml"
+	default:
+		return ".bin"
+	}
+}
+
+// mergeJSON applies the patch to the target JSON object as a JSON merge patch (RFC 7396):
+// the members of the patch replace those of the target, objects are merged recursively, and null members are removed
+func mergeJSON(target []byte, patch []byte) ([]byte, error) {
+	targetObject, err := decodeJSONObject(target)
+	if err != nil {
+		return nil, fmt.Errorf("native content in the store is not a JSON object: %w", err)
+	}
+	patchObject, err := decodeJSONObject(patch)
+	if err != nil {
+		return nil, fmt.Errorf("partial content is not a JSON object: %w", err)
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(mergeObjects(targetObject, patchObject)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func mergeObjects(target map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	for name, value := range patch {
+		if value == nil {
+			delete(target, name)
+			continue
+		}
+		if patchMember, ok := value.(map[string]interface{}); ok {
+			targetMember, ok := target[name].(map[string]interface{})
+			if !ok {
+				targetMember = make(map[string]interface{})
+			}
+			target[name] = mergeObjects(targetMember, patchMember)
+			continue
+		}
+		target[name] = value
+	}
+	return target
+}
+
+// decodeJSONObject decodes a JSON object, keeping the precision of its numbers
+func decodeJSONObject(raw []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, errors.New("null is not an object")
+	}
+	return object, nil
+}
+
+// isDryRun returns true if the writes are only logged and counted, leaving the store untouched
+func (w *storeWriter) isDryRun() bool {
+	return w.options.DryRun
+}
+
+func (w *storeWriter) ConnectivityCheck() (string, error) {
+	if err := w.store.check(); err != nil {
+		return "Native store is not writable.", err
+	}
+	return "Native store is writable.", nil
+}
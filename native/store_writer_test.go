@@ -0,0 +1,199 @@
+package native
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStoreTestMessage(t *testing.T, contentType string, body string, timestamp string, messageType string) NativeMessage {
+	msg, err := NewNativeMessage(contentType, body, timestamp, publishRef, messageType)
+	require.NoError(t, err)
+	msg.AddContentTypeHeader(aContentType)
+	msg.AddOriginSystemIDHeader(methodeOriginSystemID)
+	return msg
+}
+
+func newFileStoreTestWriter(t *testing.T, options WriterOptions) (Writer, string, func()) {
+	dir, err := ioutil.TempDir("", "native-ingester-store")
+	require.NoError(t, err)
+	parser, err := NewContentBodyParser([]string{"uuid"}, []string{"/article/@uuid"})
+	require.NoError(t, err)
+	collections, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+
+	return NewWriter("file://"+dir, collections, parser, options), dir, func() { os.RemoveAll(dir) }
+}
+
+func TestNewWriterSelectsStoreByScheme(t *testing.T) {
+	_, isHTTP := NewWriter("http://localhost:8080", nil, nil, WriterOptions{}).(*nativeWriter)
+	assert.True(t, isHTTP)
+	w, isStore := NewWriter("file:///var/lib/native-store", nil, nil, WriterOptions{}).(*storeWriter)
+	if assert.True(t, isStore) {
+		assert.Equal(t, fileStore{"/var/lib/native-store"}, w.store)
+	}
+	_, isMemory := NewWriter("mem://", nil, nil, WriterOptions{}).(*MemoryWriter)
+	assert.True(t, isMemory)
+}
+
+func TestFileStoreWritesContentAtomically(t *testing.T) {
+	w, dir, cleanup := newFileStoreTestWriter(t, WriterOptions{})
+	defer cleanup()
+
+	msg := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","title":"A title"}`, aTimestamp, messageTypeContentPublished)
+	result, err := w.WriteToCollection(msg, methodeCollectionName)
+	require.NoError(t, err)
+	assert.Equal(t, aUUID, result.UUID)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, methodeCollectionName, aUUID+".json"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"uuid":"`+aUUID+`","title":"A title","lastModified":"`+aTimestamp+`","publishReference":"`+publishRef+`"}`, string(content))
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, methodeCollectionName))
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "No temporary file should be left")
+	lastModified, _ := time.Parse(time.RFC3339, aTimestamp)
+	assert.True(t, lastModified.Equal(files[0].ModTime()), "The file should be last modified at the timestamp of the message")
+
+	_, err = w.ConnectivityCheck()
+	assert.NoError(t, err)
+}
+
+func TestFileStoreMergesPartialContent(t *testing.T) {
+	w, dir, cleanup := newFileStoreTestWriter(t, WriterOptions{})
+	defer cleanup()
+
+	full := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","title":"A title","body":{"text":"Text","words":12345678901234567890},"draft":true}`,
+		aTimestamp, messageTypeContentPublished)
+	_, err := w.WriteToCollection(full, methodeCollectionName)
+	require.NoError(t, err)
+
+	partial := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","body":{"text":"<b>New text</b>"},"draft":null}`,
+		"2017-02-16T12:57:00Z", messageTypePartialContentPublished)
+	result, err := w.WriteToCollection(partial, methodeCollectionName)
+	require.NoError(t, err)
+
+	expected := `{"body":{"text":"<b>New text</b>","words":12345678901234567890},"lastModified":"2017-02-16T12:57:00Z",` +
+		`"publishReference":"` + publishRef + `","title":"A title","uuid":"` + aUUID + `"}`
+	assert.Equal(t, expected, result.UpdatedContent, "The merged content should be returned")
+	content, err := ioutil.ReadFile(filepath.Join(dir, methodeCollectionName, aUUID+".json"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
+
+func TestFileStoreDeletesContent(t *testing.T) {
+	w, dir, cleanup := newFileStoreTestWriter(t, WriterOptions{})
+	defer cleanup()
+
+	xml := newStoreTestMessage(t, "application/xml", `<article uuid="`+aUUID+`"/>`, aTimestamp, messageTypeContentPublished)
+	_, err := w.WriteToCollection(xml, methodeCollectionName)
+	require.NoError(t, err)
+	path := filepath.Join(dir, methodeCollectionName, aUUID+".xml")
+	_, err = os.Stat(path)
+	require.NoError(t, err, "XML content should be written as an XML document")
+
+	del := newStoreTestMessage(t, "", "", aTimestamp, messageTypeContentDeleted)
+	del.AddContentUUIDHeader(aUUID)
+	_, err = w.WriteToCollection(del, methodeCollectionName)
+	require.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "The content should be deleted")
+
+	_, err = w.WriteToCollection(del, methodeCollectionName)
+	assert.NoError(t, err, "Deleting content that is not in the store should not fail")
+}
+
+func TestFileStoreConditionalWrite(t *testing.T) {
+	w, dir, cleanup := newFileStoreTestWriter(t, WriterOptions{ConditionalWrites: true})
+	defer cleanup()
+
+	newer := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","title":"Newer"}`, "2017-02-16T12:57:00Z", messageTypeContentPublished)
+	_, err := w.WriteToCollection(newer, methodeCollectionName)
+	require.NoError(t, err)
+
+	older := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","title":"Older"}`, aTimestamp, messageTypeContentPublished)
+	_, err = w.WriteToCollection(older, methodeCollectionName)
+	assert.True(t, errors.Is(err, ErrSuperseded), "The older content should be skipped, got %v", err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, methodeCollectionName, aUUID+".json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Newer")
+}
+
+func TestFileStoreConnectivityCheckFailsWithoutDirectory(t *testing.T) {
+	msg, err := NewWriter("file://", nil, nil, WriterOptions{}).ConnectivityCheck()
+	assert.Equal(t, "Native store is not writable.", msg)
+	assert.Error(t, err)
+}
+
+func TestMemoryWriter(t *testing.T) {
+	parser, err := NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	collections, err := getConfig(strCollectionsOriginIdsMap)
+	require.NoError(t, err)
+	w := NewMemoryWriter(collections, parser, WriterOptions{})
+
+	collection, err := w.GetCollection(methodeOriginSystemID, aContentType)
+	require.NoError(t, err)
+	assert.Equal(t, methodeCollectionName, collection)
+
+	msg := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","title":"A title"}`, aTimestamp, messageTypeContentPublished)
+	_, err = w.WriteToCollection(msg, collection)
+	require.NoError(t, err)
+	content, found := w.Content(collection, aUUID)
+	assert.True(t, found)
+	assert.Contains(t, content, `"title":"A title"`)
+
+	partial := newStoreTestMessage(t, "", `{"uuid":"`+aUUID+`","title":null}`, aTimestamp, messageTypePartialContentPublished)
+	result, err := w.WriteToCollection(partial, collection)
+	require.NoError(t, err)
+	assert.NotContains(t, result.UpdatedContent, "title")
+
+	_, err = NewMemoryWriter(collections, parser, WriterOptions{DryRun: true}).WriteToCollection(msg, collection)
+	require.NoError(t, err)
+	_, found = NewMemoryWriter(collections, parser, WriterOptions{DryRun: true}).Content(collection, aUUID)
+	assert.False(t, found, "A dry run should not write the content")
+}
+
+func TestPartialContentMustBeJSON(t *testing.T) {
+	w, _, cleanup := newFileStoreTestWriter(t, WriterOptions{})
+	defer cleanup()
+
+	msg := newStoreTestMessage(t, "application/xml", `<article uuid="`+aUUID+`"/>`, aTimestamp, messageTypePartialContentPublished)
+	_, err := w.WriteToCollection(msg, methodeCollectionName)
+	assert.True(t, errors.Is(err, ErrInvalidBody), "got %v", err)
+}
+
+func TestMergeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		patch    string
+		expected string
+		expError bool
+	}{
+		{"replaces members", `{"a":1,"b":2}`, `{"b":3,"c":4}`, `{"a":1,"b":3,"c":4}`, false},
+		{"removes null members", `{"a":1,"b":2}`, `{"b":null}`, `{"a":1}`, false},
+		{"merges objects", `{"a":{"b":1,"c":2}}`, `{"a":{"c":null,"d":3}}`, `{"a":{"b":1,"d":3}}`, false},
+		{"replaces arrays", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`, false},
+		{"replaces a value with an object", `{"a":1}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`, false},
+		{"target is not an object", `[1]`, `{"a":1}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := mergeJSON([]byte(tt.target), []byte(tt.patch))
+			if tt.expError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(merged))
+		})
+	}
+}
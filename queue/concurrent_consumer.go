@@ -0,0 +1,204 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/kafka/consumergroup"
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/native"
+	"github.com/Shopify/sarama"
+	"github.com/wvanbergen/kazoo-go"
+)
+
+const errConsumerNotConnected = "consumer is not connected to Kafka"
+
+// ConcurrencyConfig defines how many messages a ConcurrentConsumer handles in parallel
+type ConcurrencyConfig struct {
+	// Workers is the number of messages handled at the same time
+	Workers int
+	// QueueDepth is the number of messages each worker queues before consumption is paused
+	QueueDepth int
+	// OrderingKey returns the key of a message whose Kafka key is empty, usually the UUID of its content.
+	// Messages with the same key are handled one at a time, in the order they were consumed.
+	OrderingKey func(msg kafka.FTMessage) string
+}
+
+// ConcurrentConsumer is a Kafka consumer that handles messages in parallel,
+// preserving the order of the messages with the same key and committing offsets only up to
+// the last message whose predecessors have all been handled.
+type ConcurrentConsumer struct {
+	zookeeperConnectionString string
+	consumerGroup             string
+	topics                    []string
+	config                    *consumergroup.Config
+	retryInterval             time.Duration
+	concurrency               ConcurrencyConfig
+	join                      func() (kafka.ConsumerGrouper, error)
+
+	mutex    sync.RWMutex
+	consumer kafka.ConsumerGrouper
+	stopped  chan struct{}
+}
+
+// NewConcurrentConsumer returns a consumer that connects to Kafka when it starts listening, retrying at the given interval until it succeeds
+func NewConcurrentConsumer(zookeeperConnectionString string, consumerGroup string, topics []string, config *consumergroup.Config, retryInterval time.Duration, concurrency ConcurrencyConfig) *ConcurrentConsumer {
+	c := &ConcurrentConsumer{
+		zookeeperConnectionString: zookeeperConnectionString,
+		consumerGroup:             consumerGroup,
+		topics:                    topics,
+		config:                    config,
+		retryInterval:             retryInterval,
+		concurrency:               concurrency,
+		stopped:                   make(chan struct{}),
+	}
+	c.join = c.joinConsumerGroup
+	return c
+}
+
+func (c *ConcurrentConsumer) joinConsumerGroup() (kafka.ConsumerGrouper, error) {
+	zookeeperNodes, chroot := kazoo.ParseConnectionString(c.zookeeperConnectionString)
+	if c.config == nil {
+		c.config = kafka.DefaultConsumerConfig()
+	}
+	c.config.Zookeeper.Chroot = chroot
+	return consumergroup.JoinConsumerGroup(c.consumerGroup, c.topics, zookeeperNodes, c.config)
+}
+
+func (c *ConcurrentConsumer) connect() {
+	for {
+		consumer, err := c.join()
+		if err == nil {
+			logger.Infof(map[string]interface{}{"topics": c.topics, "consumerGroup": c.consumerGroup}, "Connected to Kafka consumer")
+			c.mutex.Lock()
+			c.consumer = consumer
+			c.mutex.Unlock()
+			return
+		}
+		logger.Errorf(map[string]interface{}{"topics": c.topics, "consumerGroup": c.consumerGroup}, err, errConsumerNotConnected)
+		time.Sleep(c.retryInterval)
+	}
+}
+
+// StartListening connects to Kafka and starts handling messages in the background
+func (c *ConcurrentConsumer) StartListening(messageHandler func(message kafka.FTMessage) error) {
+	c.connect()
+
+	c.mutex.RLock()
+	consumer := c.consumer
+	c.mutex.RUnlock()
+
+	go func() {
+		for err := range consumer.Errors() {
+			logger.Errorf(nil, err, "Error consuming messages")
+		}
+	}()
+	go c.consume(consumer, messageHandler)
+}
+
+func (c *ConcurrentConsumer) consume(consumer kafka.ConsumerGrouper, messageHandler func(message kafka.FTMessage) error) {
+	defer close(c.stopped)
+
+	pool := newOrderedWorkerPool(c.concurrency.Workers, c.concurrency.QueueDepth)
+	tracker := newOffsetTracker(consumer.CommitUpto)
+
+	for message := range consumer.Messages() {
+		msg := message
+		ftMsg := ParseFTMessage(msg.Value)
+		tracker.track(msg)
+		pool.submit(c.orderingKey(msg, ftMsg), func() {
+			if err := messageHandler(ftMsg); err != nil {
+				logger.NewEntry(ftMsg.Headers["X-Request-Id"]).
+					WithField("partition", msg.Partition).
+					WithField("offset", msg.Offset).
+					WithError(err).
+					Warn("Error handling message")
+			}
+			if err := tracker.complete(msg); err != nil {
+				logger.Errorf(nil, err, "Error committing offset %v of partition %v", msg.Offset, msg.Partition)
+			}
+		})
+	}
+
+	pool.close()
+}
+
+// orderingKey returns the Kafka key of the message, or the key given by the ordering key function.
+// Messages without any key fall back to the ordering of their partition.
+func (c *ConcurrentConsumer) orderingKey(msg *sarama.ConsumerMessage, ftMsg kafka.FTMessage) string {
+	if len(msg.Key) > 0 {
+		return string(msg.Key)
+	}
+	if c.concurrency.OrderingKey != nil {
+		if key := c.concurrency.OrderingKey(ftMsg); key != "" {
+			return key
+		}
+	}
+	return fmt.Sprintf("%s/%d", msg.Topic, msg.Partition)
+}
+
+// Shutdown stops consuming, waits for the messages being handled to complete and commits their offsets
+func (c *ConcurrentConsumer) Shutdown() {
+	c.mutex.RLock()
+	consumer := c.consumer
+	c.mutex.RUnlock()
+
+	if consumer == nil {
+		return
+	}
+	if err := consumer.Close(); err != nil {
+		logger.Errorf(nil, err, "Error closing the consumer")
+	}
+	<-c.stopped
+}
+
+// ConnectivityCheck checks that a new connection to Kafka can be established, with a distinct consumer group
+func (c *ConcurrentConsumer) ConnectivityCheck() error {
+	c.mutex.RLock()
+	connected := c.consumer != nil
+	c.mutex.RUnlock()
+
+	if !connected {
+		return errors.New(errConsumerNotConnected)
+	}
+
+	healthcheckConsumer, err := kafka.NewConsumer(kafka.Config{
+		ZookeeperConnectionString: c.zookeeperConnectionString,
+		ConsumerGroup:             c.consumerGroup + "-healthcheck",
+		Topics:                    c.topics,
+		ConsumerGroupConfig:       c.config,
+	})
+	if err != nil {
+		return err
+	}
+	healthcheckConsumer.Shutdown()
+	return nil
+}
+
+// ContentUUIDOrderingKey returns an ordering key function that keys messages by the UUID of their native content,
+// resolved like the writers do with the UUID fields and the UUID derivation of the route of the message,
+// so that all the versions of a content get the same key. Messages whose UUID cannot be resolved are given an empty key.
+func ContentUUIDOrderingKey(collections config.Provider, parser native.ContentBodyParser) func(msg kafka.FTMessage) string {
+	return func(msg kafka.FTMessage) string {
+		pubEvent := publicationEvent{msg}
+		nativeMsg, err := native.NewNativeMessage(pubEvent.Headers["Content-Type"], pubEvent.Body, "", pubEvent.transactionID(), pubEvent.messageType())
+		if err != nil {
+			return ""
+		}
+		if originSystemID, found := pubEvent.Headers["Origin-System-Id"]; found {
+			nativeMsg.AddOriginSystemIDHeader(originSystemID)
+		}
+		if contentUUID, found := pubEvent.Headers[contentUUIDHeader]; found {
+			nativeMsg.AddContentUUIDHeader(contentUUID)
+		}
+		contentUUID, _, err := native.ResolveContentUUID(nativeMsg, collections, parser)
+		if err != nil {
+			return ""
+		}
+		return contentUUID
+	}
+}
@@ -0,0 +1,295 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/native"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type consumerGrouperFake struct {
+	messages chan *sarama.ConsumerMessage
+	errors   chan error
+
+	mutex     sync.Mutex
+	committed []int64
+	closed    bool
+}
+
+func newConsumerGrouperFake() *consumerGrouperFake {
+	return &consumerGrouperFake{
+		messages: make(chan *sarama.ConsumerMessage),
+		errors:   make(chan error),
+	}
+}
+
+func (f *consumerGrouperFake) Errors() <-chan error {
+	return f.errors
+}
+
+func (f *consumerGrouperFake) Messages() <-chan *sarama.ConsumerMessage {
+	return f.messages
+}
+
+func (f *consumerGrouperFake) CommitUpto(msg *sarama.ConsumerMessage) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.committed = append(f.committed, msg.Offset)
+	return nil
+}
+
+func (f *consumerGrouperFake) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.messages)
+		close(f.errors)
+	}
+	return nil
+}
+
+func (f *consumerGrouperFake) Closed() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.closed
+}
+
+func (f *consumerGrouperFake) lastCommitted() int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.committed) == 0 {
+		return -1
+	}
+	return f.committed[len(f.committed)-1]
+}
+
+func newTestConcurrentConsumer(grouper *consumerGrouperFake, concurrency ConcurrencyConfig) *ConcurrentConsumer {
+	c := NewConcurrentConsumer("localhost:2181", "group", []string{"topic"}, nil, time.Millisecond, concurrency)
+	c.join = func() (kafka.ConsumerGrouper, error) {
+		return grouper, nil
+	}
+	return c
+}
+
+func consumerMessage(offset int64, key string, body string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic:     "topic",
+		Partition: 0,
+		Offset:    offset,
+		Key:       []byte(key),
+		Value:     []byte("FTMSG/1.0\r\nX-Request-Id: tid_" + fmt.Sprint(offset) + "\r\n\r\n" + body),
+	}
+}
+
+func TestOrderedWorkerPoolKeepsOrderPerKey(t *testing.T) {
+	pool := newOrderedWorkerPool(4, 2)
+
+	var mutex sync.Mutex
+	handled := make(map[string][]int)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i%5)
+		n := i
+		pool.submit(key, func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			handled[key] = append(handled[key], n)
+		})
+	}
+	pool.close()
+
+	for key, ns := range handled {
+		assert.Len(t, ns, 20, key)
+		for i := 1; i < len(ns); i++ {
+			assert.True(t, ns[i-1] < ns[i], "tasks of %v run out of order: %v", key, ns)
+		}
+	}
+}
+
+func TestOrderedWorkerPoolRunsKeysConcurrently(t *testing.T) {
+	pool := newOrderedWorkerPool(2, 0)
+
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	for _, key := range []string{"a", "b"} {
+		k := key
+		go pool.submit(k, func() {
+			started <- k
+			<-release
+		})
+	}
+
+	// "a" and "b" hash to different workers of a pool of two
+	timeout := time.After(time.Second)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-timeout:
+			t.Fatal("tasks with different keys did not run concurrently")
+		}
+	}
+	close(release)
+	pool.close()
+}
+
+func TestOffsetTrackerCommitsOnlyContiguousCompletedOffsets(t *testing.T) {
+	var committed []int64
+	tracker := newOffsetTracker(func(msg *sarama.ConsumerMessage) error {
+		committed = append(committed, msg.Offset)
+		return nil
+	})
+
+	msgs := make([]*sarama.ConsumerMessage, 4)
+	for i := range msgs {
+		msgs[i] = &sarama.ConsumerMessage{Topic: "topic", Partition: 0, Offset: int64(i)}
+		tracker.track(msgs[i])
+	}
+	other := &sarama.ConsumerMessage{Topic: "topic", Partition: 1, Offset: 10}
+	tracker.track(other)
+
+	assert.NoError(t, tracker.complete(msgs[2]))
+	assert.NoError(t, tracker.complete(msgs[1]))
+	assert.Empty(t, committed, "no offset should be committed while the first message is being processed")
+
+	assert.NoError(t, tracker.complete(msgs[0]))
+	assert.Equal(t, []int64{2}, committed)
+
+	assert.NoError(t, tracker.complete(other))
+	assert.NoError(t, tracker.complete(msgs[3]))
+	assert.Equal(t, []int64{2, 10, 3}, committed)
+}
+
+func TestOffsetTrackerReturnsCommitError(t *testing.T) {
+	tracker := newOffsetTracker(func(msg *sarama.ConsumerMessage) error {
+		return errors.New("commit failed")
+	})
+	msg := &sarama.ConsumerMessage{Topic: "topic", Partition: 0, Offset: 0}
+	tracker.track(msg)
+
+	assert.EqualError(t, tracker.complete(msg), "commit failed")
+}
+
+func TestConcurrentConsumerKeepsOrderPerKeyAndCommitsAfterCompletion(t *testing.T) {
+	grouper := newConsumerGrouperFake()
+	consumer := newTestConcurrentConsumer(grouper, ConcurrencyConfig{Workers: 4, QueueDepth: 20})
+
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	handled := make(map[string][]string)
+	consumer.StartListening(func(msg kafka.FTMessage) error {
+		if msg.Headers["X-Request-Id"] == "tid_0" {
+			<-release
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		handled[msg.Body] = append(handled[msg.Body], msg.Headers["X-Request-Id"])
+		return errors.New("failures do not stop the offsets from being committed")
+	})
+
+	for i := 0; i < 20; i++ {
+		grouper.messages <- consumerMessage(int64(i), fmt.Sprintf("key-%d", i%3), fmt.Sprintf("content-%d", i%3))
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(-1), grouper.lastCommitted(), "offsets must not advance past a message being handled")
+
+	close(release)
+	consumer.Shutdown()
+
+	assert.Equal(t, int64(19), grouper.lastCommitted())
+	for body, tids := range handled {
+		var expected []string
+		for i := 0; i < 20; i++ {
+			if fmt.Sprintf("content-%d", i%3) == body {
+				expected = append(expected, fmt.Sprintf("tid_%d", i))
+			}
+		}
+		assert.Equal(t, expected, tids, "messages of %v handled out of order", body)
+	}
+}
+
+func newOrderingKeyConfig(t *testing.T) *config.Configuration {
+	conf, err := config.ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "collection": "methode"}
+		],
+		"http://cmdb.ft.com/systems/wordpress": [
+			{"content_type": ".*", "collection": "wordpress", "uuid_fields": ["post.uuid"],
+			 "uuid_derivation": {"version": 3, "namespace": "url", "field": "webUrl"}}
+		]
+	}`))
+	require.NoError(t, err)
+	return conf
+}
+
+// originMessage is a consumed message without key from the given origin system
+func originMessage(offset int64, originSystemID string, body string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic:     "topic",
+		Partition: 0,
+		Offset:    offset,
+		Value:     []byte("FTMSG/1.0\r\nX-Request-Id: tid_" + fmt.Sprint(offset) + "\r\nOrigin-System-Id: " + originSystemID + "\r\n\r\n" + body),
+	}
+}
+
+func TestConcurrentConsumerOrderingKey(t *testing.T) {
+	parser, err := native.NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	consumer := newTestConcurrentConsumer(newConsumerGrouperFake(), ConcurrencyConfig{
+		Workers:     2,
+		OrderingKey: ContentUUIDOrderingKey(newOrderingKeyConfig(t), parser),
+	})
+
+	withKey := consumerMessage(0, "partition-key", `{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`)
+	assert.Equal(t, "partition-key", consumer.orderingKey(withKey, ParseFTMessage(withKey.Value)))
+
+	withUUID := consumerMessage(1, "", `{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`)
+	assert.Equal(t, "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b", consumer.orderingKey(withUUID, ParseFTMessage(withUUID.Value)))
+
+	withoutUUID := consumerMessage(2, "", `{"foo":"bar"}`)
+	assert.Equal(t, "topic/0", consumer.orderingKey(withoutUUID, ParseFTMessage(withoutUUID.Value)))
+}
+
+func TestConcurrentConsumerOrderingKeyUsesUUIDFieldsOfRoute(t *testing.T) {
+	parser, err := native.NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	consumer := newTestConcurrentConsumer(newConsumerGrouperFake(), ConcurrencyConfig{
+		Workers:     2,
+		OrderingKey: ContentUUIDOrderingKey(newOrderingKeyConfig(t), parser),
+	})
+
+	tests := []struct {
+		name   string
+		origin string
+		body   string
+		expKey string
+	}{
+		{"global UUID field", "http://cmdb.ft.com/systems/methode-web-pub", `{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`, "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"},
+		{"UUID field of the route", "http://cmdb.ft.com/systems/wordpress", `{"post":{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}}`, "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"},
+		{"global UUID field ignored by the route", "http://cmdb.ft.com/systems/wordpress", `{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`, "topic/0"},
+		{"UUID derived for the route", "http://cmdb.ft.com/systems/wordpress", `{"webUrl":"https://www.ft.com/content/12345"}`, "eed1fc2d-9522-3c49-b135-ee546764432f"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := originMessage(int64(i), tt.origin, tt.body)
+			assert.Equal(t, tt.expKey, consumer.orderingKey(msg, ParseFTMessage(msg.Value)))
+		})
+	}
+}
+
+func TestConcurrentConsumerConnectivityCheckBeforeConnecting(t *testing.T) {
+	consumer := newTestConcurrentConsumer(newConsumerGrouperFake(), ConcurrencyConfig{})
+
+	err := consumer.ConnectivityCheck()
+	require.Error(t, err)
+	assert.Equal(t, errConsumerNotConnected, err.Error())
+}
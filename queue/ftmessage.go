@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Financial-Times/kafka-client-go/kafka"
+)
+
+// The headers are found with the regular expressions of the kafka-client-go consumer, which only keeps the characters
+// of header values matching [\w\-:/.+;= ]: a value is cut at the first other character.
+var (
+	headerRegexp      = regexp.MustCompile("[\\w-]*:[\\w\\-:/.+;= ]*")
+	headerKeyRegexp   = regexp.MustCompile("[\\w-]*:")
+	headerValueRegexp = regexp.MustCompile(":[\\w-:/.+;= ]*")
+)
+
+// ParseFTMessage reads a raw message in the FT message format: a "FTMSG/1.0" line, one "Key: Value" line per header,
+// an empty line and the body. It parses the message the same way as the kafka-client-go consumer, whose parser
+// is not exported, so that the headers do not depend on the consumer reading the message.
+func ParseFTMessage(raw []byte) kafka.FTMessage {
+	msg := string(raw)
+
+	headerSection, body := msg, ""
+	if i := strings.Index(msg, "\r\n\r\n"); i != -1 {
+		headerSection, body = msg[:i], msg[i:]
+	} else if i := strings.Index(msg, "\n\n"); i != -1 {
+		headerSection, body = msg[:i], msg[i:]
+	}
+
+	headers := make(map[string]string)
+	for _, header := range headerRegexp.FindAllString(headerSection, -1) {
+		key := headerKeyRegexp.FindString(header)
+		value := headerValueRegexp.FindString(header)
+		headers[key[:len(key)-1]] = strings.TrimSpace(value[1:])
+	}
+
+	return kafka.FTMessage{
+		Headers: headers,
+		Body:    strings.TrimSpace(body),
+	}
+}
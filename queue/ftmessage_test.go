@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFTMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want kafka.FTMessage
+	}{
+		{
+			"CRLF line endings",
+			"FTMSG/1.0\r\nX-Request-Id: tid_test\r\nOrigin-System-Id: http://cmdb.ft.com/systems/methode-web-pub\r\n\r\n{\"foo\":\"bar\"}",
+			kafka.FTMessage{
+				Headers: map[string]string{"X-Request-Id": "tid_test", "Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub"},
+				Body:    `{"foo":"bar"}`,
+			},
+		},
+		{
+			"LF line endings",
+			"FTMSG/1.0\nMessage-Timestamp: 2017-02-16T12:56:16Z\nContent-Type: application/json; version=1.0\n\n{\"foo\":\"bar\"}\n",
+			kafka.FTMessage{
+				Headers: map[string]string{"Message-Timestamp": "2017-02-16T12:56:16Z", "Content-Type": "application/json; version=1.0"},
+				Body:    `{"foo":"bar"}`,
+			},
+		},
+		{
+			"header values with parameters",
+			"FTMSG/1.0\r\nContent-Type: application/vnd.ft-upp-article+json; version=1.0; charset=utf-8\r\n\r\nTest Message",
+			kafka.FTMessage{
+				Headers: map[string]string{"Content-Type": "application/vnd.ft-upp-article+json; version=1.0; charset=utf-8"},
+				Body:    "Test Message",
+			},
+		},
+		{
+			"header values cut at characters dropped by kafka-client-go",
+			"FTMSG/1.0\r\nDead-Letter-Error: invalid character 'I' looking for beginning of value\r\nX-Request-Id: tid_test\r\n\r\nI am not JSON",
+			kafka.FTMessage{
+				Headers: map[string]string{"Dead-Letter-Error": "invalid character", "X-Request-Id": "tid_test"},
+				Body:    "I am not JSON",
+			},
+		},
+		{
+			"headers found after dropped characters like kafka-client-go",
+			"FTMSG/1.0\r\nX-Note: first, second: third\r\n\r\n{}",
+			kafka.FTMessage{
+				Headers: map[string]string{"X-Note": "first", "second": "third"},
+				Body:    "{}",
+			},
+		},
+		{
+			"no body",
+			"FTMSG/1.0\r\nX-Request-Id: tid_test",
+			kafka.FTMessage{
+				Headers: map[string]string{"X-Request-Id": "tid_test"},
+				Body:    "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseFTMessage([]byte(tt.raw)))
+		})
+	}
+}
+
+func TestParseBuiltFTMessage(t *testing.T) {
+	msg := kafka.NewFTMessage(map[string]string{"X-Request-Id": "tid_test", "Message-Type": "cms-content-published"}, `{"uuid":"572d0acc-3f12-4e70-8830-8092c1042a52"}`)
+
+	assert.Equal(t, msg, ParseFTMessage([]byte(msg.Build())))
+}
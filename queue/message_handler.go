@@ -1,30 +1,110 @@
 package queue
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/metrics"
 	"github.com/Financial-Times/native-ingester/native"
 )
 
+const (
+	stageUnmarshal = "unmarshal"
+	stageRouting   = "routing"
+	stageUUID      = "uuid"
+	stageWrite     = "write"
+)
+
+// Outcomes of the ingestion of a message, as reported by the metrics
+const (
+	outcomeIngested       = "ingested"
+	outcomeNotWhitelisted = "skipped-not-whitelisted"
+	outcomeSuperseded     = "skipped-superseded"
+	outcomeRouteSkipped   = "skipped-by-route"
+	outcomeBadBody        = "bad-body"
+	outcomeUUIDMissing    = "uuid-missing"
+	outcomeUUIDInvalid    = "uuid-invalid"
+	outcomeWriteFailed    = "write-failed"
+	outcomeForwardFailed  = "forward-failed"
+	outcomeDryRun         = "dry-run"
+)
+
 // MessageHandler handles messages consumed from a queue
 type MessageHandler struct {
-	writer      native.Writer
-	producer    kafka.Producer
-	forwards    bool
-	contentType string
+	writer             native.Writer
+	producers          *ProducerPool
+	routes             config.Provider
+	forwards           bool
+	deadLetterProducer kafka.Producer
+	contentType        string
+	dryRun             bool
+}
+
+// Labels of the messages whose origin system is not configured, or which match no route
+const (
+	unknownOriginSystem = "unknown"
+	unknownContentType  = "unknown"
+)
+
+// NewMessageHandler returns a new instance of MessageHandler, writing with the routes of the configuration currently held by the provider.
+// When the writer runs in dry-run mode, the handler logs the messages it would forward or dead-letter, without publishing them.
+func NewMessageHandler(w native.Writer, routes config.Provider, contentType string) *MessageHandler {
+	return &MessageHandler{writer: w, routes: routes, contentType: contentType, dryRun: native.IsDryRun(w)}
 }
 
-// NewMessageHandler returns a new instance of MessageHandler
-func NewMessageHandler(w native.Writer, contentType string) *MessageHandler {
-	return &MessageHandler{writer: w, contentType: contentType}
+// IngestResult describes how a message went through the ingestion
+type IngestResult struct {
+	UUID       string `json:"uuid"`
+	Collection string `json:"collection"`
+	Outcome    string `json:"outcome"`
+	Forwarded  bool   `json:"forwarded"`
 }
 
 // HandleMessage implements the strategy for handling message from a queue
 func (mh *MessageHandler) HandleMessage(msg kafka.FTMessage) error {
+	_, err := mh.Ingest(msg)
+	return err
+}
+
+// Ingest handles a message like HandleMessage, returning the result of its ingestion
+func (mh *MessageHandler) Ingest(msg kafka.FTMessage) (IngestResult, error) {
+	metrics.MessagesInFlight.Inc()
+	defer metrics.MessagesInFlight.Dec()
+
 	pubEvent := publicationEvent{msg}
+	result := IngestResult{}
+	err := mh.handle(pubEvent, &result)
+	metrics.MessagesConsumed.WithLabelValues(mh.originSystemLabel(pubEvent), mh.contentTypeLabel(pubEvent), result.Outcome).Inc()
+	return result, err
+}
+
+// originSystemLabel returns the origin system of the message if it is configured, or else unknownOriginSystem,
+// as the header of the message is not trusted to keep the number of label values bounded
+func (mh *MessageHandler) originSystemLabel(pubEvent publicationEvent) string {
+	if mh.routes == nil || !mh.routes.Current().HasOriginSystem(pubEvent.originSystemID()) {
+		return unknownOriginSystem
+	}
+	return pubEvent.originSystemID()
+}
 
+// contentTypeLabel returns the content type of the route of the message as configured, or else unknownContentType,
+// as the header of the message is not trusted to keep the number of label values bounded either
+func (mh *MessageHandler) contentTypeLabel(pubEvent publicationEvent) string {
+	if mh.routes == nil {
+		return unknownContentType
+	}
+	route, err := mh.routes.Current().GetRoute(pubEvent.originSystemID(), pubEvent.Headers["Content-Type"])
+	if err != nil {
+		return unknownContentType
+	}
+	return route.ContentType
+}
+
+func (mh *MessageHandler) handle(pubEvent publicationEvent, result *IngestResult) error {
 	logger.NewEntry(pubEvent.transactionID()).WithField("Content-Type", pubEvent.contentType()).Infof("Handling new message with headers: %v", pubEvent.Headers)
 
 	writerMsg, err := pubEvent.nativeMessage()
@@ -32,50 +112,175 @@ func (mh *MessageHandler) HandleMessage(msg kafka.FTMessage) error {
 		logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
 			WithError(err).
 			Error("Error unmarshalling content body from publication event. Ignoring message.")
+		mh.deadLetter(pubEvent, stageUnmarshal, err)
+		result.Outcome = outcomeBadBody
 		return err
 	}
 
 	collection, err := mh.writer.GetCollection(pubEvent.originSystemID(), writerMsg.ContentType())
-	if err != nil {
+	if errors.Is(err, native.ErrRouteSkipped) {
 		logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
-			WithValidFlag(false).
-			Warn(fmt.Sprintf("Skipping content because of not whitelisted combination (Origin-System-Id, Content-Type): (%s, %s)", pubEvent.originSystemID(), writerMsg.ContentType()))
+			WithField("outcome", outcomeRouteSkipped).
+			Info(fmt.Sprintf("Skipping content as configured for (Origin-System-Id, Content-Type): (%s, %s)", pubEvent.originSystemID(), writerMsg.ContentType()))
+		result.Outcome = outcomeRouteSkipped
+		return nil
+	}
+	if err != nil {
+		entry := logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).WithValidFlag(false)
+		if errors.Is(err, native.ErrRouteNotConfigured) {
+			entry.Warn(fmt.Sprintf("Skipping content because of not whitelisted combination (Origin-System-Id, Content-Type): (%s, %s)", pubEvent.originSystemID(), writerMsg.ContentType()))
+		} else {
+			entry.WithError(err).Error("Failed to resolve the native collection of the content")
+		}
+		mh.deadLetter(pubEvent, stageRouting, err)
+		result.Outcome = outcomeNotWhitelisted
 		return err
 	}
+	result.Collection = collection
 
-	contentUUID, updatedContent, writerErr := mh.writer.WriteToCollection(writerMsg, collection)
-	if writerErr != nil {
+	writeResult, writerErr := mh.writer.WriteToCollection(writerMsg, collection)
+	contentUUID := writeResult.UUID
+	result.UUID = contentUUID
+	if errors.Is(writerErr, native.ErrSuperseded) {
+		metrics.SupersededWrites.WithLabelValues(collection).Inc()
 		logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
-			WithError(writerErr).
-			Error("Failed to write native content")
-		return err
+			WithUUID(contentUUID).
+			WithField("outcome", outcomeSuperseded).
+			Info("Skipped content superseded by a newer version in the native store")
+		result.Outcome = outcomeSuperseded
+		return nil
+	}
+	if writerErr != nil {
+		entry := logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
+			WithUUID(contentUUID).
+			WithField("permanent_failure", native.IsPermanent(writerErr)).
+			WithError(writerErr)
+		stage, outcome := stageWrite, outcomeWriteFailed
+		switch {
+		case errors.Is(writerErr, native.ErrUUIDNotFound):
+			stage, outcome = stageUUID, outcomeUUIDMissing
+			entry.Error("Failed to extract the UUID of the native content")
+		case errors.Is(writerErr, native.ErrInvalidUUID):
+			stage, outcome = stageUUID, outcomeUUIDInvalid
+			entry.Error("Rejected the UUID of the native content")
+		case errors.Is(writerErr, native.ErrWriterUnavailable):
+			entry.Error("Failed to write native content, the native writer is unavailable")
+		default:
+			entry.Error("Failed to write native content")
+		}
+		mh.deadLetter(pubEvent, stage, writerErr)
+		result.Outcome = outcome
+		return writerErr
+	}
+
+	if mh.dryRun {
+		logger.NewEntry(pubEvent.transactionID()).
+			WithUUID(contentUUID).
+			WithField("collection", collection).
+			WithField("forwards", mh.forwards).
+			Info("Dry run: skipping forward of consumed message")
+		result.Outcome = outcomeDryRun
+		return nil
 	}
 
 	if mh.forwards {
 
 		if writerMsg.IsPartialContent() {
-			pubEvent.Body = updatedContent
+			pubEvent.Body = writeResult.UpdatedContent
+		}
+		if writeResult.DerivedUUID {
+			pubEvent.withHeader(contentUUIDHeader, contentUUID)
 		}
 
-		logger.NewEntry(pubEvent.transactionID()).Info("Forwarding consumed message to different queue")
-		forwardErr := mh.producer.SendMessage(pubEvent.producerMsg())
+		producer, topic, forwardErr := mh.forwardProducer(pubEvent, writerMsg.ContentType())
+		if forwardErr == nil && producer == nil {
+			logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
+				WithUUID(contentUUID).
+				Info("Successfully ingested, the route of the message is not forwarded")
+			result.Outcome = outcomeIngested
+			return nil
+		}
+		if forwardErr == nil {
+			logger.NewEntry(pubEvent.transactionID()).WithField("topic", topic).Info("Forwarding consumed message to different queue")
+			start := time.Now()
+			forwardErr = producer.SendMessage(pubEvent.producerMsg())
+			metrics.ForwardDuration.Observe(time.Since(start).Seconds())
+		}
 		if forwardErr != nil {
 			logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
 				WithUUID(contentUUID).
+				WithField("topic", topic).
 				WithError(forwardErr).
 				Error("Failed to forward consumed message to a different queue")
+			result.Outcome = outcomeForwardFailed
 			return forwardErr
 		}
+		result.Forwarded = true
 		logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
 			WithUUID(contentUUID).
 			Info("Successfully ingested")
 	}
 
+	result.Outcome = outcomeIngested
 	return nil
 }
 
+func (mh *MessageHandler) deadLetter(pubEvent publicationEvent, stage string, cause error) {
+	if mh.deadLetterProducer == nil {
+		return
+	}
+	if mh.dryRun {
+		logger.NewEntry(pubEvent.transactionID()).
+			WithField("stage", stage).
+			Info("Dry run: skipping publish of failed message to the dead-letter queue")
+		return
+	}
+
+	dlMsg := pubEvent.deadLetterMsg(stage, cause, time.Now())
+	if err := mh.deadLetterProducer.SendMessage(dlMsg); err != nil {
+		logger.NewMonitoringEntry("Ingest", pubEvent.transactionID(), mh.contentType).
+			WithError(err).
+			Error("Failed to publish message to the dead-letter queue")
+		return
+	}
+	logger.NewEntry(pubEvent.transactionID()).
+		WithField("stage", stage).
+		Info("Published failed message to the dead-letter queue")
+}
+
+// forwardProducer returns the producer of the forward topic of the route of the message, or of the default topic.
+// It returns a nil producer if the route skips forwarding or there is no topic to forward to.
+func (mh *MessageHandler) forwardProducer(pubEvent publicationEvent, contentType string) (kafka.Producer, string, error) {
+	topic := ""
+	if mh.routes != nil {
+		if route, err := mh.routes.Current().GetRoute(pubEvent.originSystemID(), contentType); err == nil {
+			if route.SkipForward {
+				return nil, "", nil
+			}
+			topic = route.ForwardTopic
+		}
+	}
+	if topic == "" {
+		topic = mh.producers.defaultTopic
+	}
+	producer, err := mh.producers.Producer(topic)
+	return producer, topic, err
+}
+
 // ForwardTo sets up the message producer to forward messages after writing in the native store
 func (mh *MessageHandler) ForwardTo(p kafka.Producer) {
-	mh.producer = p
+	mh.producers = singleProducerPool(p)
 	mh.forwards = true
 }
+
+// ForwardByRoute sets up the handler to forward messages after writing in the native store to the forward topic
+// of their route, or else to the default topic of the producers. Messages of routes that skip forwarding are not forwarded.
+func (mh *MessageHandler) ForwardByRoute(producers *ProducerPool) {
+	mh.producers = producers
+	mh.forwards = true
+}
+
+// DeadLetterTo sets up the message producer to publish messages that could not be ingested
+func (mh *MessageHandler) DeadLetterTo(p kafka.Producer) {
+	mh.deadLetterProducer = p
+}
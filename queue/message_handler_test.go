@@ -2,13 +2,20 @@ package queue
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/config"
+	"github.com/Financial-Times/native-ingester/metrics"
 	"github.com/Financial-Times/native-ingester/mocks"
+	"github.com/Financial-Times/native-ingester/native"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -43,12 +50,12 @@ func init() {
 func TestWriteToNativeSuccessfullyWithoutForward(t *testing.T) {
 	w := new(mocks.WriterMock)
 	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
-	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return("", "", nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, nil)
 
 	p := new(mocks.ProducerMock)
 
-	mh := NewMessageHandler(w, contentType)
-	mh.producer = p
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.producers = singleProducerPool(p)
 	mh.HandleMessage(goodMsg)
 
 	w.AssertExpectations(t)
@@ -58,12 +65,12 @@ func TestWriteToNativeSuccessfullyWithoutForward(t *testing.T) {
 func TestWriteToNativeSuccessfullyWithForward(t *testing.T) {
 	w := new(mocks.WriterMock)
 	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
-	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return("", "", nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, nil)
 
 	p := new(mocks.ProducerMock)
 	p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(nil)
 
-	mh := NewMessageHandler(w, contentType)
+	mh := NewMessageHandler(w, nil, contentType)
 	mh.ForwardTo(p)
 	mh.HandleMessage(goodMsg)
 
@@ -83,12 +90,12 @@ func TestWritePartialContentToNativeSuccessfullyWithForward(t *testing.T) {
 	}
 
 	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
-	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return("", updatedBody, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{UpdatedContent: updatedBody}, nil)
 
 	p := new(mocks.ProducerMock)
 	p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(nil)
 
-	mh := NewMessageHandler(w, contentType)
+	mh := NewMessageHandler(w, nil, contentType)
 	mh.ForwardTo(p)
 	mh.HandleMessage(goodMsgPartialUpdated)
 
@@ -102,7 +109,7 @@ func TestWriteToNativeFailWithBadBodyMessage(t *testing.T) {
 
 	p := new(mocks.ProducerMock)
 
-	mh := NewMessageHandler(w, contentType)
+	mh := NewMessageHandler(w, nil, contentType)
 	mh.ForwardTo(p)
 	mh.HandleMessage(badBodyMsg)
 
@@ -116,7 +123,7 @@ func TestWriteToNativeFailWithNotCollectionForOriginId(t *testing.T) {
 
 	p := new(mocks.ProducerMock)
 
-	mh := NewMessageHandler(w, contentType)
+	mh := NewMessageHandler(w, nil, contentType)
 	mh.ForwardTo(p)
 	mh.HandleMessage(goodMsg)
 
@@ -127,11 +134,11 @@ func TestWriteToNativeFailWithNotCollectionForOriginId(t *testing.T) {
 func TestWriteToNativeFailBecauseOfWriter(t *testing.T) {
 	w := new(mocks.WriterMock)
 	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
-	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return("", "", errors.New("I do not want to write today!"))
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, errors.New("I do not want to write today!"))
 
 	p := new(mocks.ProducerMock)
 
-	mh := NewMessageHandler(w, contentType)
+	mh := NewMessageHandler(w, nil, contentType)
 	mh.ForwardTo(p)
 	mh.HandleMessage(goodMsg)
 
@@ -143,12 +150,12 @@ func TestForwardFailBecauseOfProducer(t *testing.T) {
 	hook := logger.NewTestHook("native-ingester")
 	w := new(mocks.WriterMock)
 	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
-	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return("", "", nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, nil)
 
 	p := new(mocks.ProducerMock)
 	p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(errors.New("Today, I am not writing on a queue."))
 
-	mh := NewMessageHandler(w, contentType)
+	mh := NewMessageHandler(w, nil, contentType)
 	mh.ForwardTo(p)
 	mh.HandleMessage(goodMsg)
 
@@ -157,3 +164,479 @@ func TestForwardFailBecauseOfProducer(t *testing.T) {
 	assert.Equal(t, "error", hook.LastEntry().Level.String())
 	assert.Equal(t, "Failed to forward consumed message to a different queue", hook.LastEntry().Message)
 }
+
+func TestDeadLetterBadBodyMessage(t *testing.T) {
+	w := new(mocks.WriterMock)
+
+	dlp := new(mocks.ProducerMock)
+	dlp.On("SendMessage", mock.MatchedBy(func(msg kafka.FTMessage) bool {
+		return msg.Body == badBodyMsg.Body && msg.Headers[deadLetterStageHeader] == stageUnmarshal
+	})).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.DeadLetterTo(dlp)
+	err := mh.HandleMessage(badBodyMsg)
+
+	assert.Error(t, err)
+	w.AssertExpectations(t)
+	dlp.AssertExpectations(t)
+}
+
+func TestDeadLetterNotWhitelistedMessage(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return("", errors.New("Collection Not Found"))
+
+	dlp := new(mocks.ProducerMock)
+	dlp.On("SendMessage", mock.MatchedBy(func(msg kafka.FTMessage) bool {
+		return msg.Headers[deadLetterStageHeader] == stageRouting && msg.Headers[deadLetterErrorHeader] == "Collection Not Found"
+	})).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.DeadLetterTo(dlp)
+	mh.HandleMessage(goodMsg)
+
+	w.AssertExpectations(t)
+	dlp.AssertExpectations(t)
+}
+
+func TestDeadLetterBecauseOfWriter(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, errors.New("I do not want to write today!"))
+
+	p := new(mocks.ProducerMock)
+	dlp := new(mocks.ProducerMock)
+	dlp.On("SendMessage", mock.MatchedBy(func(msg kafka.FTMessage) bool {
+		return msg.Headers[deadLetterStageHeader] == stageWrite &&
+			msg.Headers[deadLetterErrorHeader] == "I do not want to write today!" &&
+			msg.Headers[deadLetterAttemptsHeader] == "1" &&
+			msg.Headers[deadLetterTimestampHeader] != ""
+	})).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	mh.DeadLetterTo(dlp)
+	mh.HandleMessage(goodMsg)
+
+	w.AssertExpectations(t)
+	p.AssertExpectations(t)
+	dlp.AssertExpectations(t)
+}
+
+func TestNoDeadLetterWhenSuccessfullyIngested(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, nil)
+
+	p := new(mocks.ProducerMock)
+	p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(nil)
+	dlp := new(mocks.ProducerMock)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	mh.DeadLetterTo(dlp)
+	mh.HandleMessage(goodMsg)
+
+	w.AssertExpectations(t)
+	p.AssertExpectations(t)
+	dlp.AssertNotCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestDeadLetterFailureIsLogged(t *testing.T) {
+	hook := logger.NewTestHook("native-ingester")
+	w := new(mocks.WriterMock)
+
+	dlp := new(mocks.ProducerMock)
+	dlp.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(errors.New("Dead-letter queue is not there"))
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.DeadLetterTo(dlp)
+	mh.HandleMessage(badBodyMsg)
+
+	dlp.AssertExpectations(t)
+	assert.Equal(t, "error", hook.LastEntry().Level.String())
+	assert.Equal(t, "Failed to publish message to the dead-letter queue", hook.LastEntry().Message)
+}
+
+func TestWriteToNativeFailReturnsWriterError(t *testing.T) {
+	writerErr := &native.WriterRejectedError{StatusCode: 400}
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, writerErr)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	err := mh.HandleMessage(goodMsg)
+
+	assert.True(t, errors.Is(err, native.ErrWriterRejected), "It should return the writer error")
+	w.AssertExpectations(t)
+}
+
+func TestDeadLetterBecauseOfMissingUUID(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, native.ErrUUIDNotFound)
+
+	dlp := new(mocks.ProducerMock)
+	dlp.On("SendMessage", mock.MatchedBy(func(msg kafka.FTMessage) bool {
+		return msg.Headers[deadLetterStageHeader] == stageUUID && msg.Headers[deadLetterErrorHeader] == "UUID not found"
+	})).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.DeadLetterTo(dlp)
+	err := mh.HandleMessage(goodMsg)
+
+	assert.True(t, errors.Is(err, native.ErrUUIDNotFound), "It should return a UUID not found error")
+	w.AssertExpectations(t)
+	dlp.AssertExpectations(t)
+}
+
+func TestWriteToNativeFailWithBadBodyMessageIsInvalidBody(t *testing.T) {
+	w := new(mocks.WriterMock)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	err := mh.HandleMessage(badBodyMsg)
+
+	assert.True(t, errors.Is(err, native.ErrInvalidBody), "It should return an invalid body error")
+	w.AssertExpectations(t)
+}
+
+func TestSupersededContentIsSkipped(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{UUID: "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}, native.ErrSuperseded)
+
+	p := new(mocks.ProducerMock)
+	dlp := new(mocks.ProducerMock)
+	superseded := metrics.SupersededWrites.WithLabelValues(methodeCollection)
+	before := testutil.ToFloat64(superseded)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	mh.DeadLetterTo(dlp)
+	err := mh.HandleMessage(goodMsg)
+
+	assert.NoError(t, err, "Superseded content should not be a failure")
+	assert.Equal(t, before+1, testutil.ToFloat64(superseded), "It should count the superseded write")
+	w.AssertExpectations(t)
+	p.AssertNotCalled(t, "SendMessage", mock.Anything)
+	dlp.AssertNotCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestSkippedRouteIsNotWrittenNorDeadLettered(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return("", native.ErrRouteSkipped)
+
+	p := new(mocks.ProducerMock)
+	dlp := new(mocks.ProducerMock)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	mh.DeadLetterTo(dlp)
+	result, err := mh.Ingest(goodMsg)
+
+	assert.NoError(t, err, "Content skipped by its route should not be a failure")
+	assert.Equal(t, IngestResult{Outcome: outcomeRouteSkipped}, result)
+	w.AssertNotCalled(t, "WriteToCollection", mock.Anything, mock.Anything)
+	p.AssertNotCalled(t, "SendMessage", mock.Anything)
+	dlp.AssertNotCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestHandleMessageCountsOutcomes(t *testing.T) {
+	tests := []struct {
+		name          string
+		msg           kafka.FTMessage
+		collectionErr error
+		writerErr     error
+		forwardErr    error
+		expOutcome    string
+	}{
+		{"ingested", goodMsg, nil, nil, nil, outcomeIngested},
+		{"bad body", badBodyMsg, nil, nil, nil, outcomeBadBody},
+		{"not whitelisted", goodMsg, native.ErrRouteNotConfigured, nil, nil, outcomeNotWhitelisted},
+		{"skipped by route", goodMsg, native.ErrRouteSkipped, nil, nil, outcomeRouteSkipped},
+		{"uuid missing", goodMsg, nil, native.ErrUUIDNotFound, nil, outcomeUUIDMissing},
+		{"uuid invalid", goodMsg, nil, native.ErrInvalidUUID, nil, outcomeUUIDInvalid},
+		{"write failed", goodMsg, nil, native.ErrWriterUnavailable, nil, outcomeWriteFailed},
+		{"superseded", goodMsg, nil, native.ErrSuperseded, nil, outcomeSuperseded},
+		{"forward failed", goodMsg, nil, nil, errors.New("Today, I am not writing on a queue."), outcomeForwardFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(mocks.WriterMock)
+			w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, tt.collectionErr)
+			w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, tt.writerErr)
+			p := new(mocks.ProducerMock)
+			p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(tt.forwardErr)
+
+			counter := metrics.MessagesConsumed.WithLabelValues(methodeOriginSystemID, ".*", tt.expOutcome)
+			before := testutil.ToFloat64(counter)
+
+			mh := NewMessageHandler(w, newMethodeConfig(t), contentType)
+			mh.ForwardTo(p)
+			mh.HandleMessage(tt.msg)
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter), "It should count the message with the expected outcome")
+			assert.Equal(t, float64(0), testutil.ToFloat64(metrics.MessagesInFlight), "It should not leave messages in flight")
+		})
+	}
+}
+
+func newMethodeConfig(t *testing.T) *config.Configuration {
+	conf, err := config.ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": ".*", "collection": "methode"}
+		]
+	}`))
+	require.NoError(t, err)
+	return conf
+}
+
+func TestHandleMessageCountsUnknownOriginSystems(t *testing.T) {
+	tests := []struct {
+		name           string
+		routes         config.Provider
+		origin         string
+		expLabel       string
+		expContentType string
+	}{
+		{"configured origin system", newMethodeConfig(t), methodeOriginSystemID, methodeOriginSystemID, ".*"},
+		{"origin system not configured", newMethodeConfig(t), "http://cmdb.ft.com/systems/made-up-by-a-client", unknownOriginSystem, unknownContentType},
+		{"no configuration", nil, methodeOriginSystemID, unknownOriginSystem, unknownContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.FTMessage{Body: badBodyMsg.Body, Headers: map[string]string{}}
+			for k, v := range goodMsgHeaders {
+				msg.Headers[k] = v
+			}
+			msg.Headers["Origin-System-Id"] = tt.origin
+
+			counter := metrics.MessagesConsumed.WithLabelValues(tt.expLabel, tt.expContentType, outcomeBadBody)
+			before := testutil.ToFloat64(counter)
+
+			NewMessageHandler(new(mocks.WriterMock), tt.routes, contentType).HandleMessage(msg)
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter), "It should count the message with the %v origin system", tt.expLabel)
+		})
+	}
+}
+
+// consumedSeries returns the number of label values of the consumed messages counter
+func consumedSeries() int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		metrics.MessagesConsumed.Collect(ch)
+		close(ch)
+	}()
+	series := 0
+	for range ch {
+		series++
+	}
+	return series
+}
+
+func TestHandleMessageCountsContentTypeOfRoute(t *testing.T) {
+	mh := NewMessageHandler(new(mocks.WriterMock), newMethodeConfig(t), contentType)
+	withContentType := func(contentType string) kafka.FTMessage {
+		msg := kafka.FTMessage{Body: badBodyMsg.Body, Headers: map[string]string{}}
+		for k, v := range goodMsgHeaders {
+			msg.Headers[k] = v
+		}
+		msg.Headers["Content-Type"] = contentType
+		return msg
+	}
+	counter := metrics.MessagesConsumed.WithLabelValues(methodeOriginSystemID, ".*", outcomeBadBody)
+	mh.HandleMessage(withContentType(contentType))
+	before, series := testutil.ToFloat64(counter), consumedSeries()
+
+	mh.HandleMessage(withContentType("application/x-made-up-by-a-client; nonce=4f1c9b"))
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter), "It should count the message with the content type of its route")
+	assert.Equal(t, series, consumedSeries(), "An arbitrary Content-Type header should not add a label value")
+}
+
+func TestDeleteMessageIsForwarded(t *testing.T) {
+	deleteMsg := kafka.FTMessage{
+		Body: "",
+		Headers: map[string]string{
+			"Content-Type":      contentType,
+			"X-Request-Id":      "tid_test",
+			"Message-Timestamp": "2017-02-16T12:56:16Z",
+			"Origin-System-Id":  methodeOriginSystemID,
+			"Message-Type":      "cms-content-deleted",
+			"Content-UUID":      "572d0acc-3f12-4e70-8830-8092c1042a52",
+		},
+	}
+
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.MatchedBy(func(msg native.NativeMessage) bool {
+		return msg.IsDelete()
+	}), methodeCollection).Return(native.WriteResult{UUID: "572d0acc-3f12-4e70-8830-8092c1042a52"}, nil)
+
+	p := new(mocks.ProducerMock)
+	p.On("SendMessage", deleteMsg).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	err := mh.HandleMessage(deleteMsg)
+
+	assert.NoError(t, err)
+	w.AssertExpectations(t)
+	p.AssertExpectations(t)
+}
+
+func TestIngestReturnsResult(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{UUID: "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}, nil)
+	p := new(mocks.ProducerMock)
+	p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	result, err := mh.Ingest(goodMsg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, IngestResult{
+		UUID:       "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b",
+		Collection: methodeCollection,
+		Outcome:    outcomeIngested,
+		Forwarded:  true,
+	}, result)
+}
+
+func TestIngestReturnsResultOfFailure(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return("", native.ErrRouteNotConfigured)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	result, err := mh.Ingest(goodMsg)
+
+	assert.True(t, errors.Is(err, native.ErrRouteNotConfigured))
+	assert.Equal(t, IngestResult{Outcome: outcomeNotWhitelisted}, result)
+}
+
+func TestDryRunDoesNotForwardNorDeadLetter(t *testing.T) {
+	parser, err := native.NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	w := native.NewMemoryWriter(newMethodeConfig(t), parser, native.WriterOptions{DryRun: true})
+	p := new(mocks.ProducerMock)
+	dlp := new(mocks.ProducerMock)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	mh.DeadLetterTo(dlp)
+
+	msg := kafka.FTMessage{Body: `{"uuid": "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`, Headers: goodMsgHeaders}
+	result, err := mh.Ingest(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, IngestResult{
+		UUID:       "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b",
+		Collection: methodeCollection,
+		Outcome:    outcomeDryRun,
+	}, result)
+
+	_, err = mh.Ingest(badBodyMsg)
+	assert.Error(t, err)
+
+	_, written := w.Content(methodeCollection, "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b")
+	assert.False(t, written, "The content should not be written in dry-run mode")
+	p.AssertNotCalled(t, "SendMessage", mock.Anything)
+	dlp.AssertNotCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestHandlerIsNotDryUnlessItsWriterIs(t *testing.T) {
+	parser, err := native.NewContentBodyParser([]string{"uuid"}, nil)
+	require.NoError(t, err)
+	w := native.NewMemoryWriter(newMethodeConfig(t), parser, native.WriterOptions{})
+	p := new(mocks.ProducerMock)
+	p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+
+	result, err := mh.Ingest(kafka.FTMessage{Body: `{"uuid": "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`, Headers: goodMsgHeaders})
+	assert.NoError(t, err)
+	assert.Equal(t, outcomeIngested, result.Outcome)
+	_, written := w.Content(methodeCollection, "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b")
+	assert.True(t, written, "The content should be written")
+	p.AssertExpectations(t)
+}
+
+func TestDerivedUUIDIsForwardedAsHeader(t *testing.T) {
+	w := new(mocks.WriterMock)
+	w.On("GetCollection", methodeOriginSystemID, contentType).Return(methodeCollection, nil)
+	w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{UUID: "eed1fc2d-9522-3c49-b135-ee546764432f", DerivedUUID: true}, nil)
+	p := new(mocks.ProducerMock)
+	p.On("SendMessage", mock.MatchedBy(func(msg kafka.FTMessage) bool {
+		return msg.Headers[contentUUIDHeader] == "eed1fc2d-9522-3c49-b135-ee546764432f"
+	})).Return(nil)
+
+	mh := NewMessageHandler(w, nil, contentType)
+	mh.ForwardTo(p)
+	err := mh.HandleMessage(goodMsg)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, goodMsg.Headers, contentUUIDHeader, "It should not change the consumed message")
+	w.AssertExpectations(t)
+	p.AssertExpectations(t)
+}
+
+func TestForwardByRoute(t *testing.T) {
+	conf, err := config.ReadConfigFromReader(strings.NewReader(`{
+		"http://cmdb.ft.com/systems/methode-web-pub": [
+			{"content_type": "^application/json", "collection": "methode", "forward_topic": "NativeMethodeEvents"},
+			{"content_type": "^text/html", "collection": "methode", "skip_forward": true},
+			{"content_type": ".*", "collection": "methode"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		contentType string
+		expTopic    string
+	}{
+		{"forward topic of the route", "application/json; version=1.0", "NativeMethodeEvents"},
+		{"default topic", "text/plain", "NativeCmsPublicationEvents"},
+		{"route skipping forwarding", "text/html", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.FTMessage{Headers: map[string]string{}, Body: goodMsg.Body}
+			for k, v := range goodMsg.Headers {
+				msg.Headers[k] = v
+			}
+			msg.Headers["Content-Type"] = tt.contentType
+
+			w := new(mocks.WriterMock)
+			w.On("GetCollection", methodeOriginSystemID, tt.contentType).Return(methodeCollection, nil)
+			w.On("WriteToCollection", mock.AnythingOfType("native.NativeMessage"), methodeCollection).Return(native.WriteResult{}, nil)
+
+			producers := make(map[string]*mocks.ProducerMock)
+			pool := NewProducerPool("NativeCmsPublicationEvents", func(topic string) (kafka.Producer, error) {
+				p := new(mocks.ProducerMock)
+				p.On("SendMessage", mock.AnythingOfType("kafka.FTMessage")).Return(nil)
+				producers[topic] = p
+				return p, nil
+			})
+
+			mh := NewMessageHandler(w, conf, contentType)
+			mh.ForwardByRoute(pool)
+			result, err := mh.Ingest(msg)
+
+			assert.NoError(t, err)
+			assert.Equal(t, outcomeIngested, result.Outcome)
+			assert.Equal(t, tt.expTopic != "", result.Forwarded)
+			if tt.expTopic == "" {
+				assert.Empty(t, producers, "No producer should be used")
+				return
+			}
+			assert.Len(t, producers, 1, "Only the producer of the topic should be used")
+			if assert.Contains(t, producers, tt.expTopic) {
+				producers[tt.expTopic].AssertExpectations(t)
+			}
+		})
+	}
+}
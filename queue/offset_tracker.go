@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// offsetTracker commits the offset of a message only when the message and all the messages consumed before it
+// from the same partition have been processed, so that messages completed out of order are never skipped after a restart.
+type offsetTracker struct {
+	mutex   sync.Mutex
+	pending map[topicPartition][]*trackedMessage
+	commit  func(*sarama.ConsumerMessage) error
+}
+
+type trackedMessage struct {
+	msg  *sarama.ConsumerMessage
+	done bool
+}
+
+func newOffsetTracker(commit func(*sarama.ConsumerMessage) error) *offsetTracker {
+	return &offsetTracker{
+		pending: make(map[topicPartition][]*trackedMessage),
+		commit:  commit,
+	}
+}
+
+// track registers a consumed message, it must be called in consumption order
+func (t *offsetTracker) track(msg *sarama.ConsumerMessage) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tp := topicPartition{msg.Topic, msg.Partition}
+	t.pending[tp] = append(t.pending[tp], &trackedMessage{msg: msg})
+}
+
+// complete marks a message as processed and commits the highest offset of its partition whose predecessors are all processed
+func (t *offsetTracker) complete(msg *sarama.ConsumerMessage) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tp := topicPartition{msg.Topic, msg.Partition}
+	pending := t.pending[tp]
+	for _, tm := range pending {
+		if tm.msg == msg {
+			tm.done = true
+			break
+		}
+	}
+
+	var committable *sarama.ConsumerMessage
+	for len(pending) > 0 && pending[0].done {
+		committable = pending[0].msg
+		pending = pending[1:]
+	}
+	t.pending[tp] = pending
+
+	if committable == nil {
+		return nil
+	}
+	return t.commit(committable)
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"sync"
+
+	"github.com/Financial-Times/kafka-client-go/kafka"
+)
+
+// ProducerPool keeps a producer per topic, created the first time the topic is used
+type ProducerPool struct {
+	defaultTopic string
+	newProducer  func(topic string) (kafka.Producer, error)
+	mutex        sync.Mutex
+	producers    map[string]kafka.Producer
+}
+
+// NewProducerPool returns a new instance of ProducerPool, whose producers are created with the given function.
+// Messages are sent to the default topic unless another topic is given.
+func NewProducerPool(defaultTopic string, newProducer func(topic string) (kafka.Producer, error)) *ProducerPool {
+	return &ProducerPool{
+		defaultTopic: defaultTopic,
+		newProducer:  newProducer,
+		producers:    make(map[string]kafka.Producer),
+	}
+}
+
+// singleProducerPool returns a pool sending every message with the producer
+func singleProducerPool(p kafka.Producer) *ProducerPool {
+	return &ProducerPool{producers: map[string]kafka.Producer{"": p}}
+}
+
+// Producer returns the producer of the topic, or of the default topic if the topic is empty.
+// It returns nil if both the topic and the default topic are empty.
+func (pp *ProducerPool) Producer(topic string) (kafka.Producer, error) {
+	if topic == "" {
+		topic = pp.defaultTopic
+	}
+
+	pp.mutex.Lock()
+	defer pp.mutex.Unlock()
+	if p, found := pp.producers[topic]; found {
+		return p, nil
+	}
+	if topic == "" || pp.newProducer == nil {
+		return nil, nil
+	}
+	p, err := pp.newProducer(topic)
+	if err != nil {
+		return nil, err
+	}
+	pp.producers[topic] = p
+	return p, nil
+}
+
+// Producers returns the producers created so far by topic
+func (pp *ProducerPool) Producers() map[string]kafka.Producer {
+	pp.mutex.Lock()
+	defer pp.mutex.Unlock()
+	producers := make(map[string]kafka.Producer, len(pp.producers))
+	for topic, p := range pp.producers {
+		producers[topic] = p
+	}
+	return producers
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducerPoolCreatesOneProducerPerTopic(t *testing.T) {
+	var created []string
+	pool := NewProducerPool("NativeCmsPublicationEvents", func(topic string) (kafka.Producer, error) {
+		created = append(created, topic)
+		return new(mocks.ProducerMock), nil
+	})
+
+	defaultProducer, err := pool.Producer("")
+	assert.NoError(t, err)
+	sameProducer, _ := pool.Producer("NativeCmsPublicationEvents")
+	videoProducer, _ := pool.Producer("NativeVideoEvents")
+
+	assert.True(t, defaultProducer == sameProducer, "The default topic should have a single producer")
+	assert.False(t, defaultProducer == videoProducer, "Each topic should have its own producer")
+	assert.Equal(t, []string{"NativeCmsPublicationEvents", "NativeVideoEvents"}, created)
+	assert.Len(t, pool.Producers(), 2)
+}
+
+func TestProducerPoolRetriesFailedProducers(t *testing.T) {
+	fail := true
+	pool := NewProducerPool("", func(topic string) (kafka.Producer, error) {
+		if fail {
+			return nil, errors.New("kafka unreachable")
+		}
+		return new(mocks.ProducerMock), nil
+	})
+
+	_, err := pool.Producer("NativeVideoEvents")
+	assert.Error(t, err)
+	assert.Empty(t, pool.Producers(), "A failed producer should not be kept")
+
+	fail = false
+	p, err := pool.Producer("NativeVideoEvents")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+}
+
+func TestProducerPoolWithoutDefaultTopic(t *testing.T) {
+	pool := NewProducerPool("", func(topic string) (kafka.Producer, error) {
+		return new(mocks.ProducerMock), nil
+	})
+
+	p, err := pool.Producer("")
+	assert.NoError(t, err)
+	assert.Nil(t, p, "There should be no producer without a topic")
+}
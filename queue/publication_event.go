@@ -1,14 +1,23 @@
 package queue
 
 import (
-	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/kafka-client-go/kafka"
 	"github.com/Financial-Times/native-ingester/native"
 )
 
+const (
+	deadLetterStageHeader     = "Dead-Letter-Stage"
+	deadLetterErrorHeader     = "Dead-Letter-Error"
+	deadLetterAttemptsHeader  = "Dead-Letter-Attempts"
+	deadLetterTimestampHeader = "Dead-Letter-Timestamp"
+	contentUUIDHeader         = "Content-UUID"
+)
+
 type publicationEvent struct {
 	kafka.FTMessage
 }
@@ -33,10 +42,10 @@ func (pe *publicationEvent) nativeMessage() (native.NativeMessage, error) {
 
 	timestamp, found := pe.Headers["Message-Timestamp"]
 	if !found {
-		return native.NativeMessage{}, errors.New("publish event does not contain timestamp")
+		return native.NativeMessage{}, native.NewInvalidBodyError("publish event does not contain timestamp")
 	}
 
-	msg, err := native.NewNativeMessage(pe.Body, timestamp, pe.transactionID(), pe.messageType())
+	msg, err := native.NewNativeMessage(pe.Headers["Content-Type"], pe.Body, timestamp, pe.transactionID(), pe.messageType())
 
 	if err != nil {
 		return native.NativeMessage{}, err
@@ -47,22 +56,56 @@ func (pe *publicationEvent) nativeMessage() (native.NativeMessage, error) {
 		msg.AddHashHeader(nativeHash)
 	}
 
-	contentType, found := pe.Headers["Content-Type"]
-	if found {
-		msg.AddContentTypeHeader(contentType)
-	}
 	originSystemID, found := pe.Headers["Origin-System-Id"]
 	if found {
 		msg.AddOriginSystemIDHeader(originSystemID)
 	}
+	contentUUID, found := pe.Headers[contentUUIDHeader]
+	if found {
+		msg.AddContentUUIDHeader(contentUUID)
+	}
 	logger.NewEntry(pe.transactionID()).Infof("Constructed new NativeMessage with content-type=%s, Origin-System-Id=%s", msg.ContentType(), msg.OriginSystemID())
 
 	return msg, nil
 }
 
+// withHeader sets a header on a copy of the headers, leaving the consumed message untouched
+func (pe *publicationEvent) withHeader(name string, value string) {
+	headers := make(map[string]string, len(pe.Headers)+1)
+	for k, v := range pe.Headers {
+		headers[k] = v
+	}
+	headers[name] = value
+	pe.Headers = headers
+}
+
 func (pe *publicationEvent) producerMsg() kafka.FTMessage {
 	return kafka.FTMessage{
 		Headers: pe.Headers,
 		Body:    pe.Body,
 	}
 }
+
+// deadLetterMsg returns a copy of the consumed message annotated with the reason it could not be ingested.
+// The attempt count is carried over from previous failures, so replayed messages keep their history.
+func (pe *publicationEvent) deadLetterMsg(stage string, cause error, failedAt time.Time) kafka.FTMessage {
+	headers := make(map[string]string, len(pe.Headers)+4)
+	for k, v := range pe.Headers {
+		headers[k] = v
+	}
+
+	attempts, err := strconv.Atoi(pe.Headers[deadLetterAttemptsHeader])
+	if err != nil {
+		attempts = 0
+	}
+
+	headers[deadLetterStageHeader] = stage
+	headers[deadLetterErrorHeader] = strings.Join(strings.Fields(cause.Error()), " ")
+	headers[deadLetterAttemptsHeader] = strconv.Itoa(attempts + 1)
+	headers[deadLetterTimestampHeader] = failedAt.UTC().Format(time.RFC3339Nano)
+
+	return kafka.FTMessage{
+		Headers: headers,
+		Body:    pe.Body,
+	}
+}
@@ -1,7 +1,9 @@
 package queue
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/kafka-client-go/kafka"
 	"github.com/stretchr/testify/assert"
@@ -74,3 +76,33 @@ func TestGetProducerMessage(t *testing.T) {
 	assert.Equal(t, aMsg.Body, actualProducerMsg.Body, "It should have the same body of the consumer message")
 	assert.Equal(t, aMsg.Headers, actualProducerMsg.Headers, "It should have the same headers of the consumer message")
 }
+
+func TestGetDeadLetterMessage(t *testing.T) {
+	pe := publicationEvent{aMsg}
+	failedAt := time.Date(2017, 2, 16, 12, 56, 16, 0, time.UTC)
+	dlMsg := pe.deadLetterMsg("write", errors.New("Native writer returned\nnon-200 code"), failedAt)
+
+	assert.Equal(t, aMsg.Body, dlMsg.Body, "It should have the same body of the consumer message")
+	for k, v := range aMsg.Headers {
+		assert.Equal(t, v, dlMsg.Headers[k], "It should keep the headers of the consumer message")
+	}
+	assert.Equal(t, "write", dlMsg.Headers[deadLetterStageHeader])
+	assert.Equal(t, "Native writer returned non-200 code", dlMsg.Headers[deadLetterErrorHeader])
+	assert.Equal(t, "1", dlMsg.Headers[deadLetterAttemptsHeader])
+	assert.Equal(t, "2017-02-16T12:56:16Z", dlMsg.Headers[deadLetterTimestampHeader])
+	assert.NotContains(t, aMsg.Headers, deadLetterStageHeader, "It should not modify the consumer message headers")
+}
+
+func TestGetDeadLetterMessageOfReplayedMessage(t *testing.T) {
+	replayedMsg := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":           expectedTID,
+			deadLetterAttemptsHeader: "2",
+		},
+		Body: `{"foo":"bar"}`,
+	}
+	pe := publicationEvent{replayedMsg}
+	dlMsg := pe.deadLetterMsg("routing", errors.New("origin system not found"), time.Now())
+
+	assert.Equal(t, "3", dlMsg.Headers[deadLetterAttemptsHeader], "It should increment the attempt count")
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// orderedWorkerPool runs tasks concurrently, while tasks submitted with the same key run one at a time in submission order.
+// Each key is always assigned to the same worker, which runs its tasks in the order they were queued.
+type orderedWorkerPool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// newOrderedWorkerPool starts the given number of workers, each one queueing up to queueDepth tasks
+func newOrderedWorkerPool(workers int, queueDepth int) *orderedWorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+
+	p := &orderedWorkerPool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), queueDepth)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *orderedWorkerPool) work(queue <-chan func()) {
+	defer p.wg.Done()
+	for task := range queue {
+		task()
+	}
+}
+
+// submit queues the task to the worker of the given key, blocking while the queue of that worker is full
+func (p *orderedWorkerPool) submit(key string, task func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- task
+}
+
+// close waits for all the queued tasks to complete and stops the workers. No task can be submitted afterwards.
+func (p *orderedWorkerPool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
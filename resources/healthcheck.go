@@ -2,10 +2,12 @@ package resources
 
 import (
 	"net/http"
+	"sort"
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/config"
 	"github.com/Financial-Times/native-ingester/native"
 	"github.com/Financial-Times/service-status-go/gtg"
 )
@@ -15,17 +17,34 @@ type HealthCheck struct {
 	writer     native.Writer
 	consumer   kafka.Consumer
 	producer   kafka.Producer
+	producers  ProducerLister
+	deadLetter kafka.Producer
+	breaker    *native.CircuitBreaker
+	config     configChecker
 	panicGuide string
 }
 
+type configChecker interface {
+	Check() (string, error)
+}
+
+// ProducerLister lists the producers of the ingester by topic
+type ProducerLister interface {
+	Producers() map[string]kafka.Producer
+}
+
 // NewHealthCheck return a new instance of a native ingester HealthCheck
-func NewHealthCheck(c kafka.Consumer, p kafka.Producer, nw native.Writer, pg string) *HealthCheck {
-	return &HealthCheck{
+func NewHealthCheck(c kafka.Consumer, p kafka.Producer, nw native.Writer, conf *config.Loader, pg string) *HealthCheck {
+	hc := &HealthCheck{
 		writer:     nw,
 		consumer:   c,
 		producer:   p,
 		panicGuide: pg,
 	}
+	if conf != nil {
+		hc.config = conf
+	}
+	return hc
 }
 
 func (hc *HealthCheck) consumerQueueCheck() fthealth.Check {
@@ -52,6 +71,57 @@ func (hc *HealthCheck) producerQueueCheck() fthealth.Check {
 	}
 }
 
+// CheckProducers adds a check of the connectivity of each producer of the lister, besides the default producer
+func (hc *HealthCheck) CheckProducers(pl ProducerLister) {
+	hc.producers = pl
+}
+
+// CheckDeadLetterProducer adds a check of the connectivity of the producer of dead letters
+func (hc *HealthCheck) CheckDeadLetterProducer(p kafka.Producer) {
+	hc.deadLetter = p
+}
+
+// topicProducers returns the producers of the lister sorted by topic, without the default and dead-letter producers
+func (hc *HealthCheck) topicProducers() ([]string, map[string]kafka.Producer) {
+	if hc.producers == nil {
+		return nil, nil
+	}
+	producers := hc.producers.Producers()
+	topics := make([]string, 0, len(producers))
+	for topic, p := range producers {
+		if p == hc.producer || p == hc.deadLetter {
+			continue
+		}
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics, producers
+}
+
+func (hc *HealthCheck) topicProducerCheck(topic string, p kafka.Producer) fthealth.Check {
+	return fthealth.Check{
+		ID:               "producer-queue-" + topic,
+		BusinessImpact:   "Content or metadata routed to the " + topic + " topic will not reach the end of the publishing pipeline",
+		Name:             "ProducerQueueReachable-" + topic,
+		PanicGuide:       hc.panicGuide,
+		Severity:         2,
+		TechnicalSummary: "Producer message queue for the " + topic + " topic is not reachable/healthy",
+		Checker:          check(p.ConnectivityCheck),
+	}
+}
+
+func (hc *HealthCheck) deadLetterQueueCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "dead-letter-queue",
+		BusinessImpact:   "Content or metadata that could not be ingested will not be dead-lettered, so it cannot be replayed once the failure is fixed",
+		Name:             "DeadLetterQueueReachable",
+		PanicGuide:       hc.panicGuide,
+		Severity:         2,
+		TechnicalSummary: "Dead-letter message queue is not reachable/healthy, the messages that fail to be ingested are only logged",
+		Checker:          check(hc.deadLetter.ConnectivityCheck),
+	}
+}
+
 func (hc *HealthCheck) nativeWriterCheck() fthealth.Check {
 	return fthealth.Check{
 		ID:               "native-writer",
@@ -64,6 +134,35 @@ func (hc *HealthCheck) nativeWriterCheck() fthealth.Check {
 	}
 }
 
+// CheckBreaker adds a check that the circuit breaker around the native writer is closed
+func (hc *HealthCheck) CheckBreaker(b *native.CircuitBreaker) {
+	hc.breaker = b
+}
+
+func (hc *HealthCheck) breakerCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "native-writer-circuit-breaker",
+		BusinessImpact:   "Consumption is paused, content or metadata will be delayed until the native writer recovers",
+		Name:             "NativeWriterCircuitClosed",
+		PanicGuide:       hc.panicGuide,
+		Severity:         2,
+		TechnicalSummary: "Too many writes to the native writer failed, the circuit breaker around it is open and consumption is paused until probe writes succeed",
+		Checker:          hc.breaker.Check,
+	}
+}
+
+func (hc *HealthCheck) configCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "config",
+		BusinessImpact:   "Changes to the routing of native content to collections are not applied",
+		Name:             "ConfigurationUpToDate",
+		PanicGuide:       hc.panicGuide,
+		Severity:         3,
+		TechnicalSummary: "The configuration file could not be reloaded, the previous valid configuration is still in use. Check the file for errors.",
+		Checker:          hc.config.Check,
+	}
+}
+
 func check(fn func() error) func() (string, error) {
 	return func() (string, error) {
 		msg := "OK"
@@ -78,22 +177,38 @@ func check(fn func() error) func() (string, error) {
 
 //Handler returns the HTTP handler of the healthcheck
 func (hc *HealthCheck) Handler() func(w http.ResponseWriter, req *http.Request) {
-	checks := []fthealth.Check{hc.consumerQueueCheck(), hc.nativeWriterCheck()}
-	if hc.producer != nil {
-		checks = append(checks, hc.producerQueueCheck())
-	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		// the checks are listed on each request, as producers are created when routes name new topics
+		checks := []fthealth.Check{hc.consumerQueueCheck(), hc.nativeWriterCheck()}
+		if hc.producer != nil {
+			checks = append(checks, hc.producerQueueCheck())
+		}
+		topics, producers := hc.topicProducers()
+		for _, topic := range topics {
+			checks = append(checks, hc.topicProducerCheck(topic, producers[topic]))
+		}
+		if hc.deadLetter != nil {
+			checks = append(checks, hc.deadLetterQueueCheck())
+		}
+		if hc.breaker != nil {
+			checks = append(checks, hc.breakerCheck())
+		}
+		if hc.config != nil {
+			checks = append(checks, hc.configCheck())
+		}
 
-	healthCheck := fthealth.TimedHealthCheck{
-		HealthCheck: fthealth.HealthCheck{
-			SystemCode:  "native-ingester",
-			Name:        "Native Ingester Healthcheck",
-			Description: "It checks if kafka and native writer are available",
-			Checks:      checks,
-		},
-		Timeout: 10 * time.Second,
-	}
+		healthCheck := fthealth.TimedHealthCheck{
+			HealthCheck: fthealth.HealthCheck{
+				SystemCode:  "native-ingester",
+				Name:        "Native Ingester Healthcheck",
+				Description: "It checks if kafka and native writer are available",
+				Checks:      checks,
+			},
+			Timeout: 10 * time.Second,
+		}
 
-	return fthealth.Handler(healthCheck)
+		fthealth.Handler(healthCheck)(w, req)
+	}
 }
 
 func (hc *HealthCheck) GTG() gtg.Status {
@@ -105,14 +220,29 @@ func (hc *HealthCheck) GTG() gtg.Status {
 		return writerGtgCheck(hc.writer.ConnectivityCheck)
 	}
 
+	checks := []gtg.StatusChecker{consumerCheck}
 	if hc.producer != nil {
 		producerCheck := func() gtg.Status {
 			return gtgCheck(hc.producer.ConnectivityCheck)
 		}
-		return gtg.FailFastParallelCheck([]gtg.StatusChecker{consumerCheck, producerCheck, writerCheck})()
+		checks = append(checks, producerCheck)
+	}
+	topics, producers := hc.topicProducers()
+	for _, topic := range topics {
+		p := producers[topic]
+		checks = append(checks, func() gtg.Status {
+			return gtgCheck(p.ConnectivityCheck)
+		})
+	}
+	if hc.deadLetter != nil {
+		deadLetterCheck := func() gtg.Status {
+			return gtgCheck(hc.deadLetter.ConnectivityCheck)
+		}
+		checks = append(checks, deadLetterCheck)
 	}
+	checks = append(checks, writerCheck)
 
-	return gtg.FailFastParallelCheck([]gtg.StatusChecker{consumerCheck, writerCheck})()
+	return gtg.FailFastParallelCheck(checks)()
 }
 
 func gtgCheck(handler func() error) gtg.Status {
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/kafka-client-go/kafka"
 	"github.com/Financial-Times/native-ingester/mocks"
+	"github.com/Financial-Times/native-ingester/native"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +19,7 @@ func TestNewHealthCheckWithoutProducer(t *testing.T) {
 
 	c, _ := kafka.NewConsumer(kafka.Config{"localhost:2181", "test", []string{"testTopic"}, nil, nil})
 	nw := new(mocks.WriterMock)
-	hc := NewHealthCheck(c, nil, nw, "http://test-panic-guide.com")
+	hc := NewHealthCheck(c, nil, nw, nil, "http://test-panic-guide.com")
 
 	assert.Nil(t, hc.producer)
 	assert.NotNil(t, hc.consumer)
@@ -33,7 +35,7 @@ func TestNewHealthCheckWithProducer(t *testing.T) {
 	c, _ := kafka.NewConsumer(kafka.Config{"localhost:2181", "test", []string{"testTopic"}, nil, nil})
 	p, _ := kafka.NewProducer("localhost:9092", "testTopic", nil)
 	nw := new(mocks.WriterMock)
-	hc := NewHealthCheck(c, p, nw, "http://test-panic-guide.com")
+	hc := NewHealthCheck(c, p, nw, nil, "http://test-panic-guide.com")
 
 	assert.NotNil(t, hc.producer)
 	assert.NotNil(t, hc.consumer)
@@ -330,3 +332,127 @@ func TestUnhappyGTGCheck(t *testing.T) {
 	assert.False(t, status.GoodToGo)
 	assert.Equal(t, "I'm not fat, I'm big-boned.", status.Message)
 }
+
+func TestUnhappyConfigHealthCheck(t *testing.T) {
+	c := new(mocks.ConsumerMock)
+	c.On("ConnectivityCheck").Return(nil)
+	nw := new(mocks.WriterMock)
+	nw.On("ConnectivityCheck").Return("I'm a happy writer", nil)
+	conf := new(mocks.ConfigCheckerMock)
+	conf.On("Check").Return("Configuration could not be reloaded", errors.New("contentType value is mandatory"))
+	hc := HealthCheck{
+		consumer: c,
+		writer:   nw,
+		config:   conf,
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+
+	hc.Handler()(w, req)
+
+	assert.Equal(t, 200, w.Code, "It should return HTTP 200 OK")
+
+	assert.Contains(t, w.Body.String(), `"name":"ConsumerQueueReachable","ok":true`, "Consumer healthcheck should be happy")
+	assert.Contains(t, w.Body.String(), `"name":"NativeWriterReachable","ok":true`, "Native writer healthcheck should be happy")
+	assert.Contains(t, w.Body.String(), `"name":"ConfigurationUpToDate","ok":false`, "Config healthcheck should be unhappy")
+	assert.Contains(t, w.Body.String(), "contentType value is mandatory", "Config healthcheck should report the error")
+}
+
+func TestUnhappyConfigDoesNotAffectGTG(t *testing.T) {
+	c := new(mocks.ConsumerMock)
+	c.On("ConnectivityCheck").Return(nil)
+	nw := new(mocks.WriterMock)
+	nw.On("ConnectivityCheck").Return("I'm a happy writer", nil)
+	conf := new(mocks.ConfigCheckerMock)
+	conf.On("Check").Return("Configuration could not be reloaded", errors.New("contentType value is mandatory"))
+	hc := HealthCheck{
+		consumer: c,
+		writer:   nw,
+		config:   conf,
+	}
+
+	status := hc.GTG()
+
+	assert.True(t, status.GoodToGo)
+}
+
+type producerListerMock map[string]kafka.Producer
+
+func (pl producerListerMock) Producers() map[string]kafka.Producer {
+	return pl
+}
+
+func TestHealthCheckOfTopicProducers(t *testing.T) {
+	c := new(mocks.ConsumerMock)
+	c.On("ConnectivityCheck").Return(nil)
+	nw := new(mocks.WriterMock)
+	nw.On("ConnectivityCheck").Return("I'm a happy writer", nil)
+	p := new(mocks.ProducerMock)
+	p.On("ConnectivityCheck").Return(nil)
+	videoProducer := new(mocks.ProducerMock)
+	videoProducer.On("ConnectivityCheck").Return(errors.New("no leader for topic"))
+	deadLetterProducer := new(mocks.ProducerMock)
+	deadLetterProducer.On("ConnectivityCheck").Return(nil)
+
+	hc := NewHealthCheck(c, p, nw, nil, "http://test-panic-guide.com")
+	hc.CheckProducers(producerListerMock{
+		"NativeCmsPublicationEvents": p,
+		"NativeVideoEvents":          videoProducer,
+		"NativeIngesterDeadLetters":  deadLetterProducer,
+	})
+	hc.CheckDeadLetterProducer(deadLetterProducer)
+
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+
+	hc.Handler()(w, req)
+
+	assert.Contains(t, w.Body.String(), `"name":"ProducerQueueReachable","ok":true`, "Default producer healthcheck should be happy")
+	assert.Contains(t, w.Body.String(), `"name":"ProducerQueueReachable-NativeVideoEvents","ok":false`, "Video producer healthcheck should be unhappy")
+	assert.Contains(t, w.Body.String(), `"name":"DeadLetterQueueReachable","ok":true`, "Dead-letter producer healthcheck should be happy")
+	assert.NotContains(t, w.Body.String(), `"name":"ProducerQueueReachable-NativeIngesterDeadLetters"`, "Dead-letter producer should only be checked once")
+	assert.NotContains(t, w.Body.String(), `"name":"ProducerQueueReachable-NativeCmsPublicationEvents"`, "Default producer should only be checked once")
+
+	status := hc.GTG()
+	assert.False(t, status.GoodToGo, "GTG should fail when a topic producer is unhappy")
+}
+
+func TestHealthCheckOfDeadLetterProducer(t *testing.T) {
+	c := new(mocks.ConsumerMock)
+	c.On("ConnectivityCheck").Return(nil)
+	nw := new(mocks.WriterMock)
+	nw.On("ConnectivityCheck").Return("I'm a happy writer", nil)
+	deadLetterProducer := new(mocks.ProducerMock)
+	deadLetterProducer.On("ConnectivityCheck").Return(errors.New("no leader for topic"))
+
+	hc := NewHealthCheck(c, nil, nw, nil, "http://test-panic-guide.com")
+	hc.CheckDeadLetterProducer(deadLetterProducer)
+
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+	hc.Handler()(w, req)
+
+	assert.Contains(t, w.Body.String(), `"name":"DeadLetterQueueReachable","ok":false`, "Dead-letter producer healthcheck should be unhappy")
+	assert.Contains(t, w.Body.String(), `"technicalSummary":"Dead-letter message queue is not reachable/healthy`, "Dead-letter producer healthcheck should have its own summary")
+
+	status := hc.GTG()
+	assert.False(t, status.GoodToGo, "GTG should fail when the dead-letter producer is unhappy")
+}
+
+func TestHealthCheckOfCircuitBreaker(t *testing.T) {
+	c := new(mocks.ConsumerMock)
+	c.On("ConnectivityCheck").Return(nil)
+	nw := new(mocks.WriterMock)
+	nw.On("ConnectivityCheck").Return("I'm a happy writer", nil)
+	breaker := native.NewCircuitBreaker(native.BreakerPolicy{FailureRate: 1, Window: 1, OpenDuration: time.Minute})
+
+	hc := NewHealthCheck(c, nil, nw, nil, "http://test-panic-guide.com")
+	hc.CheckBreaker(breaker)
+
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+	hc.Handler()(w, req)
+
+	assert.Contains(t, w.Body.String(), `"name":"NativeWriterCircuitClosed","ok":true`, "Circuit breaker healthcheck should be happy while the breaker is closed")
+}
@@ -0,0 +1,110 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/native"
+	"github.com/Financial-Times/native-ingester/queue"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ftHeaders are the headers of FT messages whose names are not in the canonical form of HTTP headers
+var ftHeaders = map[string]string{
+	http.CanonicalHeaderKey("Content-UUID"): "Content-UUID",
+}
+
+// transportHeaders are HTTP headers that describe the request rather than the publication event
+var transportHeaders = map[string]bool{
+	"Accept":            true,
+	"Accept-Encoding":   true,
+	"Authorization":     true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Expect":            true,
+	"Transfer-Encoding": true,
+	"User-Agent":        true,
+}
+
+// Ingester ingests a single publication event
+type Ingester interface {
+	Ingest(msg kafka.FTMessage) (queue.IngestResult, error)
+}
+
+// IngestHandler pushes publication events received over HTTP through the same pipeline as the ones consumed from Kafka
+type IngestHandler struct {
+	ingester Ingester
+}
+
+type ingestResponse struct {
+	queue.IngestResult
+	Error string `json:"error,omitempty"`
+}
+
+// NewIngestHandler returns a new instance of IngestHandler
+func NewIngestHandler(i Ingester) *IngestHandler {
+	return &IngestHandler{ingester: i}
+}
+
+// ServeHTTP ingests the publication event made of the headers and the body of the request
+func (h *IngestHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeIngestResponse(w, http.StatusBadRequest, ingestResponse{Error: err.Error()})
+		return
+	}
+
+	msg := kafka.FTMessage{Headers: ftMessageHeaders(req.Header), Body: string(body)}
+	if msg.Headers["X-Request-Id"] == "" {
+		msg.Headers["X-Request-Id"] = "tid_" + uuid.NewV4().String()
+	}
+	logger.NewEntry(msg.Headers["X-Request-Id"]).Info("Received publication event over HTTP")
+
+	result, err := h.ingester.Ingest(msg)
+	response := ingestResponse{IngestResult: result}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	writeIngestResponse(w, ingestStatusCode(err), response)
+}
+
+func ftMessageHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for name, values := range header {
+		if transportHeaders[name] || len(values) == 0 {
+			continue
+		}
+		if ftName, found := ftHeaders[name]; found {
+			name = ftName
+		}
+		headers[name] = values[0]
+	}
+	return headers
+}
+
+// ingestStatusCode tells apart publication events that cannot be ingested from failures of the services the ingester depends on
+func ingestStatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, native.ErrInvalidBody), errors.Is(err, native.ErrUUIDNotFound), errors.Is(err, native.ErrInvalidUUID),
+		errors.Is(err, native.ErrRouteNotConfigured):
+		return http.StatusBadRequest
+	case errors.Is(err, native.ErrWriterUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeIngestResponse(w http.ResponseWriter, status int, response ingestResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Errorf(nil, err, "Failed to write the ingest response")
+	}
+}
@@ -0,0 +1,86 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/kafka-client-go/kafka"
+	"github.com/Financial-Times/native-ingester/native"
+	"github.com/Financial-Times/native-ingester/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	logger.InitDefaultLogger("native-ingester")
+}
+
+type ingesterFake struct {
+	msg    kafka.FTMessage
+	result queue.IngestResult
+	err    error
+}
+
+func (f *ingesterFake) Ingest(msg kafka.FTMessage) (queue.IngestResult, error) {
+	f.msg = msg
+	return f.result, f.err
+}
+
+func TestIngestHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    queue.IngestResult
+		err       error
+		expStatus int
+		expError  string
+	}{
+		{"ingested", queue.IngestResult{UUID: "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b", Collection: "methode", Outcome: "ingested", Forwarded: true}, nil, http.StatusOK, ""},
+		{"bad body", queue.IngestResult{Outcome: "bad-body"}, native.NewInvalidBodyError("not JSON"), http.StatusBadRequest, "not JSON"},
+		{"writer unavailable", queue.IngestResult{Collection: "methode", Outcome: "write-failed"}, native.ErrWriterUnavailable, http.StatusServiceUnavailable, native.ErrWriterUnavailable.Error()},
+		{"forward failed", queue.IngestResult{Collection: "methode", Outcome: "forward-failed"}, errors.New("kafka is down"), http.StatusBadGateway, "kafka is down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingester := &ingesterFake{result: tt.result, err: tt.err}
+			req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`))
+			req.Header.Set("X-Request-Id", "tid_test")
+			req.Header.Set("Origin-System-Id", "http://cmdb.ft.com/systems/methode-web-pub")
+			req.Header.Set("Content-UUID", "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b")
+			req.Header.Set("User-Agent", "curl")
+			w := httptest.NewRecorder()
+
+			NewIngestHandler(ingester).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expStatus, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.Equal(t, kafka.FTMessage{
+				Headers: map[string]string{
+					"X-Request-Id":     "tid_test",
+					"Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub",
+					"Content-UUID":     "ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b",
+				},
+				Body: `{"uuid":"ce3a6f36-7a0f-11e7-b1ec-1d8af6f1d43b"}`,
+			}, ingester.msg)
+
+			var response ingestResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Equal(t, tt.result, response.IngestResult)
+			assert.Equal(t, tt.expError, response.Error)
+		})
+	}
+}
+
+func TestIngestHandlerGeneratesTransactionID(t *testing.T) {
+	ingester := &ingesterFake{}
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+
+	NewIngestHandler(ingester).ServeHTTP(w, req)
+
+	assert.True(t, strings.HasPrefix(ingester.msg.Headers["X-Request-Id"], "tid_"))
+}
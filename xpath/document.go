@@ -0,0 +1,102 @@
+package xpath
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Node is an element of an XML document, or the document itself
+type Node struct {
+	// Name is empty for the document node
+	Name     xml.Name
+	Attr     []xml.Attr
+	children []interface{}
+}
+
+// Parse reads an XML document, failing if it is not well-formed or has no root element
+func Parse(r io.Reader) (*Node, error) {
+	doc := &Node{}
+	stack := []*Node{doc}
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			if parent == doc && len(doc.elements()) > 0 {
+				return nil, errors.New("XML document has more than one root element")
+			}
+			n := &Node{Name: t.Name, Attr: t.Copy().Attr}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if parent != doc {
+				parent.children = append(parent.children, string(t))
+			}
+		}
+	}
+	if len(doc.elements()) == 0 {
+		return nil, errors.New("XML document has no root element")
+	}
+	return doc, nil
+}
+
+// elements returns the child elements of the node, in document order
+func (n *Node) elements() []*Node {
+	var result []*Node
+	for _, c := range n.children {
+		if e, ok := c.(*Node); ok {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Text returns the text of the node and all its descendants, in document order
+func (n *Node) Text() string {
+	var b strings.Builder
+	n.writeText(&b)
+	return b.String()
+}
+
+func (n *Node) writeText(b *strings.Builder) {
+	for _, c := range n.children {
+		switch v := c.(type) {
+		case string:
+			b.WriteString(v)
+		case *Node:
+			v.writeText(b)
+		}
+	}
+}
+
+// ownText returns the text directly under the node, without the text of its descendants
+func (n *Node) ownText() string {
+	var b strings.Builder
+	for _, c := range n.children {
+		if s, ok := c.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// attribute returns the value of the attribute with the local name, if any
+func (n *Node) attribute(local string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,261 @@
+package xpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// predicate filters the elements selected by a step, given their position among them
+type predicate interface {
+	test(n *Node, position int, size int) bool
+}
+
+type positionPredicate struct {
+	// position is counted from 1, or 0 for last()
+	position int
+}
+
+func (p positionPredicate) test(n *Node, position int, size int) bool {
+	if p.position == 0 {
+		return position == size
+	}
+	return position == p.position
+}
+
+// valuePredicate tests an attribute or the text of a child element, or only their existence if there is no operator
+type valuePredicate struct {
+	attribute bool
+	name      string
+	operator  string
+	literal   string
+}
+
+func (p valuePredicate) test(n *Node, position int, size int) bool {
+	var values []string
+	if p.attribute {
+		if value, found := n.attribute(p.name); found {
+			values = append(values, value)
+		}
+	} else {
+		for _, e := range n.elements() {
+			if p.name == "*" || e.Name.Local == p.name {
+				values = append(values, e.Text())
+			}
+		}
+	}
+
+	if p.operator == "" {
+		return len(values) > 0
+	}
+	// as in XPath, a comparison is true if it is true for any of the values
+	for _, value := range values {
+		if (value == p.literal) == (p.operator == "=") {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	expr string
+	pos  int
+}
+
+func (p *parser) parsePath() (*Path, error) {
+	if p.expr == "" {
+		return nil, errors.New("empty expression")
+	}
+
+	path := &Path{}
+	if p.peek() != '/' {
+		// a path without the leading / starts with a step from the document, like article/uuid
+		if err := p.parseStep(path, false); err != nil {
+			return nil, err
+		}
+	}
+	for !p.done() {
+		if path.attribute != "" || path.text {
+			return nil, p.errorf("attributes and text() can only be selected by the last step")
+		}
+		if err := p.expect('/'); err != nil {
+			return nil, err
+		}
+		recursive := false
+		if !p.done() && p.peek() == '/' {
+			p.pos++
+			recursive = true
+		}
+		if err := p.parseStep(path, recursive); err != nil {
+			return nil, err
+		}
+	}
+	return path, nil
+}
+
+func (p *parser) parseStep(path *Path, recursive bool) error {
+	if p.done() {
+		return p.errorf("expected a step")
+	}
+	switch {
+	case p.peek() == '@':
+		if recursive {
+			// //@id selects the attribute of any element
+			path.steps = append(path.steps, step{recursive: true, name: "*"})
+		}
+		p.pos++
+		name, err := p.parseName()
+		if err != nil {
+			return err
+		}
+		path.attribute = name
+		return nil
+	case strings.HasPrefix(p.expr[p.pos:], "text()"):
+		p.pos += len("text()")
+		if recursive {
+			path.steps = append(path.steps, step{recursive: true, name: "*"})
+		}
+		path.text = true
+		return nil
+	}
+
+	name, err := p.parseName()
+	if err != nil {
+		return err
+	}
+	s := step{recursive: recursive, name: name}
+	for !p.done() && p.peek() == '[' {
+		p.pos++
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+		s.predicates = append(s.predicates, pred)
+	}
+	path.steps = append(path.steps, s)
+	return nil
+}
+
+func (p *parser) parsePredicate() (predicate, error) {
+	p.skipSpaces()
+	if p.done() {
+		return nil, p.errorf("expected a predicate")
+	}
+
+	if strings.HasPrefix(p.expr[p.pos:], "last()") {
+		p.pos += len("last()")
+		p.skipSpaces()
+		return positionPredicate{}, nil
+	}
+	if isDigit(p.peek()) {
+		start := p.pos
+		for !p.done() && isDigit(p.peek()) {
+			p.pos++
+		}
+		position, err := strconv.Atoi(p.expr[start:p.pos])
+		if err != nil || position < 1 {
+			return nil, p.errorf("invalid position %q", p.expr[start:p.pos])
+		}
+		p.skipSpaces()
+		return positionPredicate{position}, nil
+	}
+
+	pred := valuePredicate{}
+	if p.peek() == '@' {
+		p.pos++
+		pred.attribute = true
+	}
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	pred.name = name
+	p.skipSpaces()
+	for _, operator := range []string{"!=", "="} {
+		if strings.HasPrefix(p.expr[p.pos:], operator) {
+			p.pos += len(operator)
+			p.skipSpaces()
+			literal, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			pred.operator, pred.literal = operator, literal
+			p.skipSpaces()
+			break
+		}
+	}
+	return pred, nil
+}
+
+// parseName parses a name or *, dropping any namespace prefix
+func (p *parser) parseName() (string, error) {
+	start := p.pos
+	for !p.done() && strings.IndexByte("/[]@=!'\" \t()", p.peek()) < 0 {
+		p.pos++
+	}
+	name := p.expr[start:p.pos]
+	if name == "" {
+		if p.done() {
+			return "", p.errorf("expected a name")
+		}
+		return "", p.errorf("unexpected character %q", p.peek())
+	}
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("%v steps are not supported, at offset %v", name, start)
+	}
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || (strings.Contains(name, "*") && name != "*") {
+		return "", fmt.Errorf("invalid name %q at offset %v", p.expr[start:p.pos], start)
+	}
+	return name, nil
+}
+
+func (p *parser) parseString() (string, error) {
+	if p.done() || (p.peek() != '\'' && p.peek() != '"') {
+		return "", p.errorf("expected a quoted string")
+	}
+	quote := p.peek()
+	end := strings.IndexByte(p.expr[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.expr[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+func (p *parser) expect(c byte) error {
+	if p.done() || p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) skipSpaces() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.expr)
+}
+
+func (p *parser) peek() byte {
+	return p.expr[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%v at offset %v", fmt.Sprintf(format, args...), p.pos)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,113 @@
+// Package xpath evaluates a subset of XPath 1.0 against XML documents, enough to find identifiers in native content.
+//
+// Expressions are made of steps separated by / or //, such as /article/@id, //identifier[@authority='x'] or
+// article/meta/uuid/text(). A step is an element name or *, optionally followed by predicates: a position ([1],
+// [last()]), the existence of an attribute or a child element ([@id], [uuid]) or their comparison with a literal
+// ([@type='uuid'], [type!='legacy']). The last step can select an attribute (@id) or the text of the elements (text()).
+// Names are matched on their local part, any namespace prefix is ignored. An expression without a leading / is
+// evaluated from the document, so article/uuid is the same as /article/uuid.
+//
+// The standard library has no XPath. github.com/antchfx/xmlquery, the usual choice, matches names with their prefix,
+// so an expression like //uuid would have to become //*[local-name()='uuid'] to find the ft:uuid of native content.
+package xpath
+
+import "fmt"
+
+// Path is a compiled XPath expression
+type Path struct {
+	expr  string
+	steps []step
+	// attribute is the attribute selected by the last step, if any
+	attribute string
+	// text is true if the last step selects the text of the elements
+	text bool
+}
+
+// step selects the child elements of the current nodes, or of the current nodes and all their descendants if recursive
+type step struct {
+	recursive  bool
+	name       string
+	predicates []predicate
+}
+
+// Compile parses an XPath expression
+func Compile(expr string) (*Path, error) {
+	p := &parser{expr: expr}
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath %q: %v", expr, err)
+	}
+	path.expr = expr
+	return path, nil
+}
+
+// String returns the expression the path was compiled from
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find returns the string values matched by the path in the document, in document order:
+// the values of the selected attributes, the text of the selected elements or their text and the text of their descendants
+func (p *Path) Find(doc *Node) []string {
+	nodes := []*Node{doc}
+	for _, s := range p.steps {
+		var next []*Node
+		for _, n := range nodes {
+			parents := []*Node{n}
+			if s.recursive {
+				parents = descendants(n)
+			}
+			for _, parent := range parents {
+				next = append(next, s.selectFrom(parent)...)
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		nodes = next
+	}
+
+	var values []string
+	for _, n := range nodes {
+		switch {
+		case p.attribute != "":
+			if value, found := n.attribute(p.attribute); found {
+				values = append(values, value)
+			}
+		case p.text:
+			values = append(values, n.ownText())
+		default:
+			values = append(values, n.Text())
+		}
+	}
+	return values
+}
+
+// selectFrom returns the child elements of the node matching the step, with positions counted among the matching children
+func (s step) selectFrom(n *Node) []*Node {
+	var matched []*Node
+	for _, e := range n.elements() {
+		if s.name == "*" || s.name == e.Name.Local {
+			matched = append(matched, e)
+		}
+	}
+	for _, pred := range s.predicates {
+		var kept []*Node
+		for i, e := range matched {
+			if pred.test(e, i+1, len(matched)) {
+				kept = append(kept, e)
+			}
+		}
+		matched = kept
+	}
+	return matched
+}
+
+// descendants returns the node followed by all its descendant elements, depth first
+func descendants(n *Node) []*Node {
+	result := []*Node{n}
+	for _, e := range n.elements() {
+		result = append(result, descendants(e)...)
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package xpath
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<ft:article xmlns:ft="http://www.ft.com/ns" id="07ac9fad-6434-47c7-b7c4-34361a048d07">
+	<title>A <b>bold</b> title</title>
+	<identifiers>
+		<identifier authority="http://api.ft.com/system/FTCOM-METHODE">methode-id</identifier>
+		<identifier authority="http://api.ft.com/system/WORDPRESS">wordpress-id</identifier>
+	</identifiers>
+	<meta>
+		<uuid>b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11</uuid>
+		<item><type>legacy</type><value>first</value></item>
+		<item><type>current</type><value>second</value></item>
+	</meta>
+</ft:article>`
+
+func TestFind(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testDocument))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected []string
+	}{
+		{"absolute path", "/article/meta/uuid", []string{"b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11"}},
+		{"relative path", "article/meta/uuid", []string{"b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11"}},
+		{"namespace prefix", "/ft:article/@id", []string{"07ac9fad-6434-47c7-b7c4-34361a048d07"}},
+		{"attribute", "/article/@id", []string{"07ac9fad-6434-47c7-b7c4-34361a048d07"}},
+		{"descendants", "//uuid", []string{"b9e4d2a6-9c5e-4d0e-8b0f-3a1c2f9e7d11"}},
+		{"descendant attribute", "//@authority", []string{"http://api.ft.com/system/FTCOM-METHODE", "http://api.ft.com/system/WORDPRESS"}},
+		{"wildcard", "/article/meta/*/value", []string{"first", "second"}},
+		{"text of descendants", "/article/title", []string{"A bold title"}},
+		{"own text", "/article/title/text()", []string{"A  title"}},
+		{"position", "//identifier[2]", []string{"wordpress-id"}},
+		{"last position", "//item[last()]/value", []string{"second"}},
+		{"attribute comparison", "//identifier[@authority='http://api.ft.com/system/WORDPRESS']", []string{"wordpress-id"}},
+		{"attribute existence", "//*[@authority]", []string{"methode-id", "wordpress-id"}},
+		{"child comparison", `//item[type = "current"]/value`, []string{"second"}},
+		{"negated child comparison", "//item[type!='current']/value", []string{"first"}},
+		{"several predicates", "//identifier[@authority][1]", []string{"methode-id"}},
+		{"missing element", "/article/uuid", nil},
+		{"missing attribute", "/article/@uuid", nil},
+		{"wrong root", "/post/@id", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p.Find(doc))
+		})
+	}
+}
+
+func TestCompileInvalidExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ""},
+		{"root only", "/"},
+		{"trailing slash", "/article/"},
+		{"attribute before last step", "/article/@id/uuid"},
+		{"text before last step", "/article/text()/uuid"},
+		{"unclosed predicate", "//identifier[1"},
+		{"zero position", "//identifier[0]"},
+		{"unterminated string", "//identifier[@authority='x]"},
+		{"unquoted literal", "//identifier[@authority=x]"},
+		{"parent step", "/article/../uuid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseInvalidDocuments(t *testing.T) {
+	for _, doc := range []string{"", "not xml", "<article>", "<a/><b/>", `{"uuid": "07ac9fad-6434-47c7-b7c4-34361a048d07"}`} {
+		_, err := Parse(strings.NewReader(doc))
+		assert.Error(t, err, "It should fail to parse %q", doc)
+	}
+}
+
+func TestString(t *testing.T) {
+	p, err := Compile("/article/@id")
+	require.NoError(t, err)
+	assert.Equal(t, "/article/@id", p.String())
+}